@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+)
+
+// AnniversaryType distinguishes the kinds of entries in a -B file
+type AnniversaryType int
+
+const (
+	BIRTHDAY AnniversaryType = 1 + iota
+	ANNIVERSARY
+)
+
+// userAnniversary is one line of a -B birthdays and anniversaries file
+type userAnniversary struct {
+	Type AnniversaryType
+	Date hdate.HDate // original Hebrew date
+	Name string
+	Note string // optional
+}
+
+// anniversaryEvent is a Hebrew birthday or anniversary observed on Date,
+// Years after the original date.
+type anniversaryEvent struct {
+	Date  hdate.HDate
+	Years int
+	Entry userAnniversary
+}
+
+func (ev anniversaryEvent) GetDate() hdate.HDate {
+	return ev.Date
+}
+
+func (ev anniversaryEvent) Render(locale string) string {
+	var kind, years string
+	if isHebrewLocale(locale) {
+		kind = "יום הולדת"
+		if ev.Entry.Type == ANNIVERSARY {
+			kind = "יום נישואין"
+		}
+		years = strconv.Itoa(ev.Years) + " שנים"
+		if ev.Years == 1 {
+			years = "שנה אחת"
+		}
+	} else {
+		kind = "birthday"
+		if ev.Entry.Type == ANNIVERSARY {
+			kind = "anniversary"
+		}
+		years = strconv.Itoa(ev.Years) + " years"
+		if ev.Years == 1 {
+			years = "1 year"
+		}
+	}
+	str := ev.Entry.Name + " (" + kind + ", " + years + ")"
+	if ev.Entry.Note != "" {
+		str += ": " + ev.Entry.Note
+	}
+	return str
+}
+
+func (ev anniversaryEvent) GetFlags() event.HolidayFlags {
+	return event.USER_EVENT
+}
+
+func (ev anniversaryEvent) GetEmoji() string {
+	if ev.Entry.Type == ANNIVERSARY {
+		return "💑"
+	}
+	return "🎂"
+}
+
+func (ev anniversaryEvent) Basename() string {
+	return ev.Entry.Name
+}
+
+// anniversaryEvents observes each entry in every Hebrew year from start
+// to end, using hdate.GetBirthdayOrAnniversary so that the rules for
+// Adar, 30 Cheshvan and 30 Kislev are applied.
+func anniversaryEvents(entries []userAnniversary, start, end hdate.HDate) []event.CalEvent {
+	events := make([]event.CalEvent, 0, len(entries))
+	startAbs, endAbs := start.Abs(), end.Abs()
+	for hyear := start.Year(); hyear <= end.Year(); hyear++ {
+		for _, entry := range entries {
+			if hyear <= entry.Date.Year() {
+				continue
+			}
+			hd, err := hdate.GetBirthdayOrAnniversary(hyear, entry.Date)
+			if err != nil {
+				continue
+			}
+			if abs := hd.Abs(); abs >= startAbs && abs <= endAbs {
+				events = append(events, anniversaryEvent{
+					Date:  hd,
+					Years: hyear - entry.Date.Year(),
+					Entry: entry,
+				})
+			}
+		}
+	}
+	return events
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"time"
+
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+	"github.com/hebcal/hebcal-go/zmanim"
+)
+
+// chametzDeadlines are the latest times on Erev Pesach to eat chametz
+// (the end of the 4th sha'ah zmanit) and to burn it (the end of the 5th).
+var chametzDeadlines = []struct {
+	desc  string
+	hours float64
+	day   zmanimDay
+}{
+	{"Achilat Chametz, sof zeman (MGA)", 4, dayMinutes(72)},
+	{"Achilat Chametz, sof zeman (GRA)", 4, dayGRA},
+	{"Biur Chametz, sof zeman (MGA)", 5, dayMinutes(72)},
+	{"Biur Chametz, sof zeman (GRA)", 5, dayGRA},
+}
+
+// isErevPesach matches hebcal-go's Erev Pesach holiday event
+func isErevPesach(ev event.CalEvent) bool {
+	holidayEv, ok := ev.(event.HolidayEvent)
+	return ok && holidayEv.Desc == "Erev Pesach"
+}
+
+// chametzEvents adds the chametz deadlines after each Erev Pesach in
+// events. It also returns Bedikat Chametz, the search for chametz on
+// the night before Erev Pesach, for merging into events. When Erev
+// Pesach is on Shabbat, the search is on Thursday night and the
+// chametz is burned on Friday.
+func chametzEvents(events []event.CalEvent, obs observer, calOptions *hebcal.CalOptions) ([]event.CalEvent, []event.CalEvent) {
+	start, end := calendarRange(calOptions)
+	result := make([]event.CalEvent, 0, len(events)+8)
+	extra := make([]event.CalEvent, 0, 4)
+	for _, ev := range events {
+		result = append(result, ev)
+		if !isErevPesach(ev) {
+			continue
+		}
+		erevPesach := ev.GetDate()
+		biurDay := erevPesach
+		if erevPesach.Weekday() == time.Saturday {
+			biurDay = erevPesach.Prev()
+		}
+		for _, deadline := range chametzDeadlines {
+			hd := erevPesach
+			if deadline.hours == 5 {
+				hd = biurDay
+			}
+			z := newDayZmanim(obs, hd)
+			t := z.shaahOffset(deadline.hours, deadline.day)
+			if t.IsZero() {
+				continue
+			}
+			timedEv := hebcal.NewTimedEvent(hd, deadline.desc, CHAMETZ_DEADLINE, t, 0, ev, calOptions)
+			if hd == erevPesach {
+				result = append(result, timedEv)
+			} else if hd.Abs() >= start.Abs() {
+				extra = append(extra, timedEv)
+			}
+		}
+		bedika := hdate.FromRD(biurDay.Abs() - 1)
+		if bedika.Abs() < start.Abs() || bedika.Abs() > end.Abs() {
+			continue
+		}
+		z := newDayZmanim(obs, bedika)
+		if t := z.timeAtAngle(zmanim.Tzeit3SmallStars, false); !t.IsZero() {
+			extra = append(extra, hebcal.NewTimedEvent(bedika, "Bedikat Chametz", BEDIKAT_CHAMETZ, t, 0, ev, calOptions))
+		}
+	}
+	return result, extra
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// inputFormat is one of the kinds of file read by -I, -Y and -B
+type inputFormat struct {
+	option string
+	name   string
+	parse  func(line string) error
+}
+
+var inputFormats = []inputFormat{
+	{"-I", "user events", func(line string) error {
+		_, err := parseUserRule(line)
+		return err
+	}},
+	{"-Y", "yahrtzeit", func(line string) error {
+		_, err := parseYahrzeitLine(line)
+		return err
+	}},
+	{"-B", "birthdays", func(line string) error {
+		_, err := parseBirthdayLine(line)
+		return err
+	}},
+}
+
+func lookupInputFormat(option string) (inputFormat, bool) {
+	for _, format := range inputFormats {
+		if format.option == option {
+			return format, true
+		}
+	}
+	switch option {
+	case "--infile":
+		return inputFormats[0], true
+	case "--yahrtzeit":
+		return inputFormats[1], true
+	case "--birthdays":
+		return inputFormats[2], true
+	}
+	return inputFormat{}, false
+}
+
+// isGregorianYahrzeitLine returns true if line begins with three
+// numbers, like a Gregorian "MM DD YYYY" date in a -Y file, whether or
+// not that date is valid.
+func isGregorianYahrzeitLine(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return false
+	}
+	for _, field := range fields[0:3] {
+		if _, err := strconv.Atoi(field); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// detectInputFormat guesses the kind of file from its first line that
+// is not blank or a comment: a -B line begins with its type, and a -Y
+// line begins with three numbers or a complete Hebrew date.
+func detectInputFormat(f *os.File) inputFormat {
+	defer f.Seek(0, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		switch strings.ToLower(strings.Fields(line)[0]) {
+		case "birthday", "anniversary":
+			return inputFormats[2]
+		}
+		if isGregorianYahrzeitLine(line) {
+			return inputFormats[1]
+		}
+		if _, err := parseYahrzeitLine(line); err == nil {
+			return inputFormats[1]
+		}
+		break
+	}
+	return inputFormats[0]
+}
+
+// runCheck implements "hebcal check [-I|-Y|-B] FILE...". Each file is
+// validated as the kind given by the preceding option, or else the kind
+// guessed from its contents. It exits non-zero if any problem is found.
+func runCheck(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: hebcal check [-I|-Y|-B] FILE...\n")
+		os.Exit(1)
+	}
+	status := 0
+	var format *inputFormat
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			f, ok := lookupInputFormat(arg)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "hebcal check: unknown option %s\n", arg)
+				os.Exit(1)
+			}
+			format = &f
+			continue
+		}
+		f, err := os.Open(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			status = 1
+			continue
+		}
+		fileFormat := detectInputFormat(f)
+		if format != nil {
+			fileFormat = *format
+			format = nil
+		}
+		numErrors := scanInputFile(f, arg, fileFormat.parse)
+		f.Close()
+		if numErrors != 0 {
+			fmt.Printf("%s: %d error(s) in %s file\n", arg, numErrors, fileFormat.name)
+			status = 1
+		} else {
+			fmt.Printf("%s: %s file OK\n", arg, fileFormat.name)
+		}
+	}
+	os.Exit(status)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hebcal/hebcal-go/zmanim"
+	"github.com/pborman/getopt/v2"
+)
+
+// accentFolds maps accented Latin letters to their base letter
+var accentFolds = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ā': 'a', 'ă': 'a', 'ą': 'a',
+	'ç': 'c', 'ć': 'c', 'č': 'c', 'ď': 'd', 'đ': 'd',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e', 'ė': 'e', 'ę': 'e', 'ě': 'e',
+	'ğ': 'g', 'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ī': 'i', 'ı': 'i',
+	'ł': 'l', 'ñ': 'n', 'ń': 'n', 'ň': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o', 'ō': 'o', 'ő': 'o',
+	'ř': 'r', 'ś': 's', 'š': 's', 'ş': 's', 'ß': 's', 'ť': 't', 'ţ': 't',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ū': 'u', 'ů': 'u', 'ű': 'u',
+	'ý': 'y', 'ÿ': 'y', 'ź': 'z', 'ż': 'z', 'ž': 'z',
+}
+
+// normalizeCityName lowercases and removes accents and punctuation, so
+// that "Tel-Aviv" matches "Tel Aviv" and "Zichron Ya'akov" matches
+// "Zichron Yaakov".
+func normalizeCityName(name string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range strings.ToLower(name) {
+		if base, ok := accentFolds[r]; ok {
+			r = base
+		}
+		switch r {
+		case '\'', '’', '`', '.':
+			continue
+		case ' ', '-', '_', ',', '\t':
+			space = sb.Len() != 0
+			continue
+		}
+		if space {
+			sb.WriteByte(' ')
+			space = false
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// cityMatch is one result of a city search; lower scores are better.
+type cityMatch struct {
+	Location  zmanim.Location
+	Geonameid int64 // 0 for hebcal's built-in cities
+	Score     int
+}
+
+// cityMatchScore rates how well the normalized query q matches a city:
+// 0 for the same name, 1 for a prefix of the name or one of its words,
+// 2 for a substring or the country code, and 3 or more for names within
+// a small edit distance. It returns -1 for no match.
+func cityMatchScore(q string, loc *zmanim.Location) int {
+	name := normalizeCityName(loc.Name)
+	switch {
+	case name == q:
+		return 0
+	case strings.HasPrefix(name, q) || strings.Contains(name, " "+q):
+		return 1
+	case strings.Contains(name, q) || strings.EqualFold(q, loc.CountryCode):
+		return 2
+	}
+	maxDist := 1 + len([]rune(q))/4
+	dist := editDistance(q, name)
+	// also compare with the beginning of the name, for partial queries
+	if n := len([]rune(q)); n < len([]rune(name)) {
+		dist = minInt(dist, editDistance(q, string([]rune(name)[:n]))+1)
+	}
+	if dist <= maxDist {
+		return 2 + dist
+	}
+	return -1
+}
+
+// searchCities searches hebcal's built-in cities and any cities from
+// "hebcal geo-import". If country is not empty, only cities with that
+// ISO country code are returned.
+func searchCities(query, country string) []cityMatch {
+	q := normalizeCityName(query)
+	matches := make([]cityMatch, 0, 10)
+	consider := func(loc zmanim.Location, geonameid int64) {
+		if country != "" && !strings.EqualFold(country, loc.CountryCode) {
+			return
+		}
+		score := 0
+		if q != "" {
+			score = cityMatchScore(q, &loc)
+		}
+		if score >= 0 {
+			matches = append(matches, cityMatch{Location: loc, Geonameid: geonameid, Score: score})
+		}
+	}
+	for _, loc := range zmanim.AllCities() {
+		consider(loc, 0)
+	}
+	if db, err := loadGeoDB(); err == nil && (q != "" || country != "") {
+		for id, place := range db.Cities {
+			consider(zmanim.Location{
+				Name:        place.Name,
+				CountryCode: place.CountryCode,
+				Latitude:    place.Latitude,
+				Longitude:   place.Longitude,
+				TimeZoneId:  place.TimeZoneId,
+			}, id)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score < matches[j].Score
+		}
+		return matches[i].Location.Name < matches[j].Location.Name
+	})
+	return matches
+}
+
+// suggestCities returns up to max names of built-in cities close to
+// name, for the "unknown city" error.
+func suggestCities(name string, max int) []string {
+	q := normalizeCityName(name)
+	matches := make([]cityMatch, 0, max)
+	for _, loc := range zmanim.AllCities() {
+		if score := cityMatchScore(q, &loc); score >= 0 {
+			matches = append(matches, cityMatch{Location: loc, Score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score < matches[j].Score
+	})
+	names := make([]string, 0, max)
+	for _, m := range matches {
+		if len(names) == max {
+			break
+		}
+		names = append(names, m.Location.Name)
+	}
+	return names
+}
+
+// runCities implements "hebcal cities [--search QUERY] [--country CC] [QUERY]".
+func runCities(args []string) {
+	opt := getopt.New()
+	opt.SetProgram("hebcal cities")
+	opt.SetParameters("[QUERY]")
+	search := opt.StringLong("search", 's', "", "Find cities by name, allowing for typos", "QUERY")
+	country := opt.StringLong("country", 0, "", "Only list cities in this ISO country code, e.g. IL", "CC")
+	if err := opt.Getopt(append([]string{"cities"}, args...), nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		opt.PrintUsage(os.Stderr)
+		os.Exit(1)
+	}
+	query := *search
+	if rest := opt.Args(); len(rest) != 0 {
+		query = strings.TrimSpace(query + " " + strings.Join(rest, " "))
+	}
+	if query == "" && *country == "" {
+		for _, city := range zmanim.AllCities() {
+			fmt.Printf("%s (%.5f,%.5f  %s)\n",
+				city.Name, city.Latitude, city.Longitude, city.TimeZoneId)
+		}
+		os.Exit(0)
+	}
+	matches := searchCities(query, *country)
+	if len(matches) == 0 {
+		if query == "" {
+			fmt.Fprintf(os.Stderr, "no cities in country %s\n", *country)
+		} else {
+			fmt.Fprintf(os.Stderr, "no cities match %s\n", query)
+		}
+		os.Exit(1)
+	}
+	for _, m := range matches {
+		city := m.Location
+		if m.Geonameid != 0 {
+			fmt.Printf("%s, %s (%.5f,%.5f  %s) --geonameid %d\n", city.Name, city.CountryCode,
+				city.Latitude, city.Longitude, city.TimeZoneId, m.Geonameid)
+		} else {
+			fmt.Printf("%s (%.5f,%.5f  %s)\n",
+				city.Name, city.Latitude, city.Longitude, city.TimeZoneId)
+		}
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hebcalConfig holds the options from a config file, each in the form
+// --name=value. Defaults apply to every run; a profile applies only
+// when selected with --profile.
+type hebcalConfig struct {
+	Defaults []string
+	Profiles map[string][]string
+}
+
+// configFileName returns $XDG_CONFIG_HOME/hebcal/config.toml, falling
+// back to ~/.config/hebcal/config.toml.
+func configFileName() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "hebcal", "config.toml")
+}
+
+var configSectionRegex = regexp.MustCompile(`^\[\s*profile\.([A-Za-z0-9_-]+|"[^"]+")\s*\]$`)
+var configKeyRegex = regexp.MustCompile(`^([A-Za-z0-9_-]+)\s*[=:]\s*(.*)$`)
+
+// readConfigFile reads a config file of the form
+//
+//	# defaults
+//	city = "Jerusalem"
+//	sedrot = true
+//
+//	[profile.shul]
+//	havdalah-mins = 50
+//
+// where each key is the long name of a command line option. The file
+// is optional; a missing file returns an empty config.
+func readConfigFile(filename string, isOption func(name string) bool) (*hebcalConfig, error) {
+	config := &hebcalConfig{Profiles: make(map[string][]string)}
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	profile := "" // the current [profile.NAME], or "" for defaults
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		lineNumber++
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if m := configSectionRegex.FindStringSubmatch(line); m != nil {
+			profile = strings.Trim(m[1], `"`)
+			if _, ok := config.Profiles[profile]; !ok {
+				config.Profiles[profile] = []string{}
+			}
+			continue
+		}
+		m := configKeyRegex.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("invalid line: %s:%d", filename, lineNumber)
+		}
+		name := m[1]
+		if !isOption(name) {
+			return nil, fmt.Errorf("unknown option %s: %s:%d", name, filename, lineNumber)
+		}
+		value, err := parseConfigValue(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("%v: %s:%d", err, filename, lineNumber)
+		}
+		arg := "--" + name + "=" + value
+		if profile == "" {
+			config.Defaults = append(config.Defaults, arg)
+		} else {
+			config.Profiles[profile] = append(config.Profiles[profile], arg)
+		}
+	}
+	return config, scanner.Err()
+}
+
+// parseConfigValue accepts a quoted string, true or false, or a bare
+// word or number, followed by an optional # comment.
+func parseConfigValue(str string) (string, error) {
+	if str == "" {
+		return "", errors.New("missing value")
+	}
+	if str[0] == '"' || str[0] == '\'' {
+		words, rest, err := splitQuoted(str)
+		if err != nil {
+			return "", err
+		}
+		if rest = strings.TrimSpace(rest); rest != "" && rest[0] != '#' {
+			return "", fmt.Errorf("unexpected text after value: %s", rest)
+		}
+		return words, nil
+	}
+	if i := strings.Index(str, "#"); i != -1 {
+		str = str[:i]
+	}
+	str = strings.TrimSpace(str)
+	if _, err := strconv.ParseFloat(str, 64); err != nil && strings.ContainsAny(str, " \t") {
+		return "", fmt.Errorf("values with spaces must be quoted: %s", str)
+	}
+	return str, nil
+}
+
+// splitQuoted parses the quoted string at the beginning of str,
+// returning its contents and the remainder of str.
+func splitQuoted(str string) (string, string, error) {
+	quote := str[0]
+	var sb strings.Builder
+	for i := 1; i < len(str); i++ {
+		c := str[i]
+		switch {
+		case c == quote:
+			return sb.String(), str[i+1:], nil
+		case c == '\\' && quote == '"' && i+1 < len(str):
+			i++
+			sb.WriteByte(str[i])
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated quoted string: %s", str)
+}
+
+// splitShellWords splits HEBCAL_OPTS into arguments as a shell would,
+// so that e.g. --city "Tel Aviv" or --city='Tel Aviv' is one option.
+func splitShellWords(str string) ([]string, error) {
+	words := make([]string, 0, 8)
+	var sb strings.Builder
+	inWord := false
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, sb.String())
+				sb.Reset()
+				inWord = false
+			}
+		case c == '"' || c == '\'':
+			quoted, rest, err := splitQuoted(str[i:])
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteString(quoted)
+			inWord = true
+			i = len(str) - len(rest) - 1
+		case c == '\\' && i+1 < len(str):
+			i++
+			sb.WriteByte(str[i])
+			inWord = true
+		default:
+			sb.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, sb.String())
+	}
+	return words, nil
+}
+
+// findProfileArg returns the NAME of the last --profile NAME or
+// --profile=NAME in args, or "" if there is none.
+func findProfileArg(args []string) string {
+	name := ""
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		} else if arg == "--profile" && i+1 < len(args) {
+			name = args[i+1]
+		} else if strings.HasPrefix(arg, "--profile=") {
+			name = strings.TrimPrefix(arg, "--profile=")
+		}
+	}
+	return name
+}
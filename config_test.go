@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseConfigValue(t *testing.T) {
+	tests := []struct {
+		str  string
+		want string
+	}{
+		{"true", "true"},
+		{"Jerusalem", "Jerusalem"},
+		{"-2.5", "-2.5"},
+		{"18 # minutes", "18"},
+		{`"Tel Aviv"`, "Tel Aviv"},
+		{`"Tel Aviv" # comment`, "Tel Aviv"},
+		{`'Tel Aviv'`, "Tel Aviv"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`'back\slash'`, `back\slash`},
+		{`"#not a comment"`, "#not a comment"},
+	}
+	for _, test := range tests {
+		got, err := parseConfigValue(test.str)
+		if err != nil {
+			t.Errorf("parseConfigValue(%q): %v", test.str, err)
+		} else if got != test.want {
+			t.Errorf("parseConfigValue(%q) = %q, want %q", test.str, got, test.want)
+		}
+	}
+}
+
+func TestParseConfigValueErrors(t *testing.T) {
+	for _, str := range []string{
+		"",
+		"Tel Aviv",
+		`"Tel Aviv`,
+		`"Tel" Aviv`,
+	} {
+		if got, err := parseConfigValue(str); err == nil {
+			t.Errorf("parseConfigValue(%q) = %q, want error", str, got)
+		}
+	}
+}
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		str  string
+		want []string
+	}{
+		{"", []string{}},
+		{"  -c  -E\t", []string{"-c", "-E"}},
+		{`--city "Tel Aviv" -s`, []string{"--city", "Tel Aviv", "-s"}},
+		{`--city='Tel Aviv'`, []string{"--city=Tel Aviv"}},
+		{`--city=Tel\ Aviv`, []string{"--city=Tel Aviv"}},
+		{`"" x`, []string{"", "x"}},
+		{`"a \"b\"" 'c \d'`, []string{`a "b"`, `c \d`}},
+	}
+	for _, test := range tests {
+		got, err := splitShellWords(test.str)
+		if err != nil {
+			t.Errorf("splitShellWords(%q): %v", test.str, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitShellWords(%q) = %q, want %q", test.str, got, test.want)
+		}
+	}
+	if got, err := splitShellWords(`--city "Tel Aviv`); err == nil {
+		t.Errorf("splitShellWords with unterminated quote = %q, want error", got)
+	}
+}
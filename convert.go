@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/locales"
+	"github.com/pborman/getopt/v2"
+)
+
+var afterSunset_sw = false
+
+// convertDate converts a single date and prints the result. Gregorian
+// dates are printed as Hebrew dates in every locale; Hebrew dates are
+// printed as Gregorian dates.
+func convertDate(out io.Writer, str string) error {
+	hd, isGreg, err := parseDate(str)
+	if err != nil {
+		return err
+	}
+	if !isGreg {
+		fmt.Fprintf(out, "%s = %s, %s\n", hd.String(),
+			hd.Weekday().String()[0:3], formatGregDate(hd))
+		return nil
+	}
+	gregStr := formatGregDate(hd)
+	if afterSunset_sw {
+		gregStr += " after sunset"
+		hd = hd.Next()
+	}
+	fmt.Fprintf(out, "%s:\n", gregStr)
+	hebDate := event.NewHebrewDateEvent(hd)
+	for _, locale := range locales.AllLocales {
+		fmt.Fprintf(out, "  %-20s%s\n", locale, hebDate.Render(locale))
+	}
+	return nil
+}
+
+// convertBatch converts one date per line, writing a single
+// tab-separated line per input line.
+func convertBatch(out io.Writer, in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	w := bufio.NewWriter(out)
+	lineNumber := 0
+	ok := true
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		hd, isGreg, err := parseDate(line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error, %v: stdin:%d\n", err, lineNumber)
+			ok = false
+			continue
+		}
+		if isGreg {
+			if afterSunset_sw {
+				hd = hd.Next()
+			}
+			fmt.Fprintf(w, "%s\t%s\n", line, event.NewHebrewDateEvent(hd).Render(lang))
+		} else {
+			fmt.Fprintf(w, "%s\t%s\n", line, formatGregDate(hd))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("some dates could not be converted")
+	}
+	return nil
+}
+
+// runConvert implements "hebcal convert". With no arguments (or "-")
+// dates are read from stdin, one per line.
+func runConvert(args []string) {
+	opt := getopt.New()
+	opt.SetProgram("hebcal convert")
+	opt.SetParameters("[DATE | -]")
+	opt.FlagLong(&afterSunset_sw, "after-sunset", 0, "Gregorian dates are after sunset (use the next Hebrew day)")
+	opt.FlagLong(&lang, "lang", 0, "Language of the Hebrew dates converted from stdin", "LANG")
+	if err := opt.Getopt(append([]string{"convert"}, args...), nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		opt.PrintUsage(os.Stderr)
+		os.Exit(1)
+	}
+	checkLang()
+	args = opt.Args()
+	var err error
+	if len(args) == 0 || (len(args) == 1 && args[0] == "-") {
+		err = convertBatch(os.Stdout, os.Stdin)
+	} else {
+		err = convertDate(os.Stdout, strings.Join(args, " "))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+)
+
+// Column headings understood by the Outlook and Google Calendar importers
+var csvHeader = []string{
+	"Subject",
+	"Start Date",
+	"Start Time",
+	"End Date",
+	"End Time",
+	"All Day Event",
+	"Description",
+	"Location",
+	"Categories",
+}
+
+// writeCSV writes events as RFC 4180 comma-separated values.
+//
+// The output begins with a UTF-8 byte order mark; without it, Outlook
+// and Excel assume a legacy code page and garble Hebrew text.
+func writeCSV(out io.Writer, events []event.CalEvent, calOptions *hebcal.CalOptions) error {
+	if _, err := io.WriteString(out, "\ufeff"); err != nil {
+		return err
+	}
+	w := csv.NewWriter(out)
+	w.UseCRLF = true
+	if err := w.Write(csvHeader); err != nil {
+		return err
+	}
+	timeFormat := "3:04 PM"
+	if calOptions.Hour24 {
+		timeFormat = "15:04"
+	}
+	for _, ev := range events {
+		date := formatGregDate(ev.GetDate())
+		record := []string{eventTitle(ev, lang), date, "", date, "", "True", "", "",
+			strings.Join(flagNames(ev.GetFlags()), ";")}
+		if timedEv, ok := ev.(hebcal.TimedEvent); ok {
+			timeStr := timedEv.EventTime.Format(timeFormat)
+			record[2] = timeStr
+			record[4] = timeStr
+			record[5] = "False"
+			if timedEv.LinkedEvent != nil {
+				record[6] = timedEv.LinkedEvent.Render(lang)
+			}
+			if calOptions.Location != nil {
+				record[7] = calOptions.Location.Name
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hebcal/greg"
+	"github.com/hebcal/hdate"
+)
+
+var gematriyaValues = map[rune]int{
+	'א': 1, 'ב': 2, 'ג': 3, 'ד': 4, 'ה': 5, 'ו': 6, 'ז': 7, 'ח': 8, 'ט': 9,
+	'י': 10, 'כ': 20, 'ך': 20, 'ל': 30, 'מ': 40, 'ם': 40, 'נ': 50, 'ן': 50,
+	'ס': 60, 'ע': 70, 'פ': 80, 'ף': 80, 'צ': 90, 'ץ': 90,
+	'ק': 100, 'ר': 200, 'ש': 300, 'ת': 400,
+}
+
+func isGeresh(r rune) bool {
+	return r == '׳' || r == '\''
+}
+
+func isGershayim(r rune) bool {
+	return r == '״' || r == '"'
+}
+
+// parseGematriya converts Hebrew numerals such as "ט״ו" or "ה׳תשפ״ד"
+// to a number. A geresh followed by more letters marks thousands.
+func parseGematriya(str string) (int, error) {
+	runes := []rune(strings.TrimSpace(str))
+	if len(runes) == 0 {
+		return 0, errors.New("empty Hebrew number")
+	}
+	total := 0
+	value := 0
+	for i, r := range runes {
+		if isGeresh(r) {
+			if i == 0 {
+				return 0, fmt.Errorf("invalid Hebrew number: %s", str)
+			}
+			if i != len(runes)-1 {
+				total += value * 1000
+				value = 0
+			}
+			continue
+		} else if isGershayim(r) {
+			if i == 0 || i == len(runes)-1 {
+				return 0, fmt.Errorf("invalid Hebrew number: %s", str)
+			}
+			continue
+		}
+		v, ok := gematriyaValues[r]
+		if !ok {
+			return 0, fmt.Errorf("invalid Hebrew number: %s", str)
+		}
+		value += v
+	}
+	return total + value, nil
+}
+
+func isGematriya(str string) bool {
+	for _, r := range str {
+		if _, ok := gematriyaValues[r]; !ok && !isGeresh(r) && !isGershayim(r) {
+			return false
+		}
+	}
+	return str != ""
+}
+
+// parseNumber accepts either Arabic digits or Hebrew numerals.
+func parseNumber(str string) (int, error) {
+	if isGematriya(str) {
+		return parseGematriya(str)
+	}
+	return strconv.Atoi(str)
+}
+
+// parseHebrewYear accepts Arabic digits or Hebrew numerals.
+// Hebrew numerals without the thousands (e.g. תשפ״ה) are taken
+// to be in the current millennium, as is customary.
+func parseHebrewYear(str string) (int, error) {
+	if !isGematriya(str) {
+		return strconv.Atoi(str)
+	}
+	year, err := parseGematriya(str)
+	if err != nil {
+		return 0, err
+	}
+	if year < 1000 {
+		year += 5000
+	}
+	return year, nil
+}
+
+// parseHebrewMonth wraps hdate.MonthFromName, correcting Adar II to
+// Adar in a non-leap year.
+func parseHebrewMonth(str string, year int) (hdate.HMonth, error) {
+	month, err := hdate.MonthFromName(str)
+	if err != nil && strings.HasPrefix(str, "ב") {
+		// "ט״ו בשבט" is written with the prefix ב (in)
+		month, err = hdate.MonthFromName(strings.TrimPrefix(str, "ב"))
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unknown Hebrew month: %s", str)
+	}
+	if month == hdate.Adar2 && !hdate.IsLeapYear(year) {
+		month = hdate.Adar1
+	}
+	return month, nil
+}
+
+// newHDate validates the day before calling hdate.New, which would
+// otherwise silently roll over into the following month.
+func newHDate(year int, month hdate.HMonth, day int) (hdate.HDate, error) {
+	if year < 1 {
+		return hdate.HDate{}, fmt.Errorf("invalid Hebrew year: %d", year)
+	}
+	if month > hdate.HMonth(hdate.MonthsInYear(year)) {
+		return hdate.HDate{}, fmt.Errorf("%d is not a leap year", year)
+	}
+	if day < 1 || day > hdate.DaysInMonth(month, year) {
+		monthName := hdate.New(year, month, 1).MonthName("en")
+		return hdate.HDate{}, fmt.Errorf("invalid day %d for %s %d",
+			day, monthName, year)
+	}
+	return hdate.New(year, month, day), nil
+}
+
+// newGregDate validates a Gregorian date and converts it to an HDate.
+func newGregDate(year int, month time.Month, day int) (hdate.HDate, error) {
+	if month < time.January || month > time.December {
+		return hdate.HDate{}, fmt.Errorf("invalid month: %d", month)
+	}
+	if day < 1 || day > greg.DaysIn(month, year) {
+		return hdate.HDate{}, fmt.Errorf("invalid day %d for %s %d",
+			day, month.String(), year)
+	}
+	return hdate.FromGregorian(year, month, day), nil
+}
+
+var isoDateRegex = regexp.MustCompile(`^(-?\d+)-(\d\d?)-(\d\d?)$`)
+var usDateRegex = regexp.MustCompile(`^(\d\d?)/(\d\d?)/(-?\d+)$`)
+
+// parseDate parses a Gregorian date (YYYY-MM-DD or MM/DD/YYYY) or a
+// Hebrew date ("15 Nisan 5784", "ט״ו ניסן תשפ״ד"). The second result is
+// true when the input was a Gregorian date.
+func parseDate(str string) (hdate.HDate, bool, error) {
+	str = strings.TrimSpace(str)
+	if m := isoDateRegex.FindStringSubmatch(str); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		hd, err := newGregDate(year, time.Month(month), day)
+		return hd, true, err
+	}
+	if m := usDateRegex.FindStringSubmatch(str); m != nil {
+		month, _ := strconv.Atoi(m[1])
+		day, _ := strconv.Atoi(m[2])
+		year, _ := strconv.Atoi(m[3])
+		hd, err := newGregDate(year, time.Month(month), day)
+		return hd, true, err
+	}
+	fields := strings.Fields(str)
+	if len(fields) < 3 {
+		return hdate.HDate{}, false, fmt.Errorf("unrecognized date: %s", str)
+	}
+	day, err := parseNumber(fields[0])
+	if err != nil {
+		return hdate.HDate{}, false, fmt.Errorf("invalid day of month: %s", fields[0])
+	}
+	year, err := parseHebrewYear(fields[len(fields)-1])
+	if err != nil {
+		return hdate.HDate{}, false, fmt.Errorf("invalid Hebrew year: %s", fields[len(fields)-1])
+	}
+	month, err := parseHebrewMonth(strings.Join(fields[1:len(fields)-1], " "), year)
+	if err != nil {
+		return hdate.HDate{}, false, err
+	}
+	hd, err := newHDate(year, month, day)
+	return hd, false, err
+}
+
+func hasHebrewPunctuation(str string) bool {
+	return strings.ContainsAny(str, "׳״'\"")
+}
+
+// checkGematriyaArg rejects Hebrew numerals that could just as well be
+// the abbreviation of a month name, such as "אב" (Av, or 3) or "כה"
+// (Kislev, or 25). Writing the number with ׳ or ״ resolves it.
+func checkGematriyaArg(str string) error {
+	if hasHebrewPunctuation(str) {
+		return nil
+	}
+	if month, err := hdate.MonthFromName(str); err == nil {
+		return fmt.Errorf("ambiguous argument '%s': it could be the month %s or a number; "+
+			"write Hebrew numbers with ׳ or ״ (e.g. כ״ה, תשפ״ה)", str, month.String())
+	}
+	return nil
+}
+
+// parseYearArg parses a positional year given either in digits or in
+// Hebrew numerals. The second result is true for Hebrew numerals,
+// which always denote a Hebrew year.
+func parseYearArg(str string) (int, bool, error) {
+	if !isGematriya(str) {
+		year, err := strconv.Atoi(str)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid year: %s", str)
+		}
+		return year, false, nil
+	}
+	if err := checkGematriyaArg(str); err != nil {
+		return 0, true, err
+	}
+	year, err := parseHebrewYear(str)
+	return year, true, err
+}
+
+// parseDayArg parses a positional day of month given either in digits
+// or in Hebrew numerals.
+func parseDayArg(str string) (int, error) {
+	if isGematriya(str) {
+		if err := checkGematriyaArg(str); err != nil {
+			return 0, err
+		}
+	}
+	day, err := parseNumber(str)
+	if err != nil || day < 1 || day > 31 {
+		return 0, fmt.Errorf("invalid day of month: %s", str)
+	}
+	return day, nil
+}
+
+// isMonthArg returns true if str names a month, either as a Gregorian
+// month number or a Hebrew month name. Hebrew numerals such as ט״ו
+// are never month names, even though hdate.MonthFromName accepts them.
+func isMonthArg(str string) bool {
+	if mm, err := strconv.Atoi(str); err == nil {
+		return mm >= 1 && mm <= 12
+	}
+	if strings.ContainsAny(str, "״\"") {
+		return false
+	}
+	_, err := hdate.MonthFromName(strings.TrimPrefix(str, "ב"))
+	return err == nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import "github.com/hebcal/hebcal-go/event"
+
+// Flags for events hebcal adds to those from hebcal-go, using bits
+// the event package leaves unused
+const (
+	// Latest times to eat and to burn chametz on Erev Pesach
+	CHAMETZ_DEADLINE event.HolidayFlags = 1 << (31 - iota)
+	// The search for chametz on the night before Erev Pesach
+	BEDIKAT_CHAMETZ
+	// The window for saying Kiddush Levana each month
+	KIDDUSH_LEVANA
+	// The haftarah read on Shabbat or a holiday
+	HAFTARAH
+)
+
+var holidayFlagNames = []struct {
+	flag event.HolidayFlags
+	name string
+}{
+	{event.CHAG, "CHAG"},
+	{event.LIGHT_CANDLES, "LIGHT_CANDLES"},
+	{event.YOM_TOV_ENDS, "YOM_TOV_ENDS"},
+	{event.CHUL_ONLY, "CHUL_ONLY"},
+	{event.IL_ONLY, "IL_ONLY"},
+	{event.LIGHT_CANDLES_TZEIS, "LIGHT_CANDLES_TZEIS"},
+	{event.CHANUKAH_CANDLES, "CHANUKAH_CANDLES"},
+	{event.ROSH_CHODESH, "ROSH_CHODESH"},
+	{event.MINOR_FAST, "MINOR_FAST"},
+	{event.SPECIAL_SHABBAT, "SPECIAL_SHABBAT"},
+	{event.PARSHA_HASHAVUA, "PARSHA_HASHAVUA"},
+	{event.DAF_YOMI, "DAF_YOMI"},
+	{event.OMER_COUNT, "OMER_COUNT"},
+	{event.MODERN_HOLIDAY, "MODERN_HOLIDAY"},
+	{event.MAJOR_FAST, "MAJOR_FAST"},
+	{event.SHABBAT_MEVARCHIM, "SHABBAT_MEVARCHIM"},
+	{event.MOLAD, "MOLAD"},
+	{event.USER_EVENT, "USER_EVENT"},
+	{event.HEBREW_DATE, "HEBREW_DATE"},
+	{event.MINOR_HOLIDAY, "MINOR_HOLIDAY"},
+	{event.EREV, "EREV"},
+	{event.CHOL_HAMOED, "CHOL_HAMOED"},
+	{event.MISHNA_YOMI, "MISHNA_YOMI"},
+	{event.YOM_KIPPUR_KATAN, "YOM_KIPPUR_KATAN"},
+	{event.ZMANIM, "ZMANIM"},
+	{event.YERUSHALMI_YOMI, "YERUSHALMI_YOMI"},
+	{event.NACH_YOMI, "NACH_YOMI"},
+	{CHAMETZ_DEADLINE, "CHAMETZ_DEADLINE"},
+	{BEDIKAT_CHAMETZ, "BEDIKAT_CHAMETZ"},
+	{KIDDUSH_LEVANA, "KIDDUSH_LEVANA"},
+	{HAFTARAH, "HAFTARAH"},
+}
+
+// flagNames decodes an event bitmask into the names of its flags,
+// in the order they are declared in the event package.
+func flagNames(flags event.HolidayFlags) []string {
+	names := make([]string, 0, 2)
+	for _, f := range holidayFlagNames {
+		if (flags & f.flag) != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
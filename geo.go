@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hebcal/hebcal-go/zmanim"
+)
+
+// geoPlace is a city from a GeoNames dump or a ZIP code from a CSV file
+type geoPlace struct {
+	Name        string
+	Admin1      string // state or province
+	CountryCode string
+	Latitude    float64
+	Longitude   float64
+	TimeZoneId  string
+	Population  int
+	Elevation   float64 // meters above sea level, 0 if unknown
+}
+
+// geoDB is the on-disk location cache built by "hebcal geo-import"
+type geoDB struct {
+	Cities map[int64]geoPlace  // by GeoNames id
+	Zips   map[string]geoPlace // by US ZIP code
+}
+
+// geoDBFileName returns the cache file, in $XDG_CACHE_HOME/hebcal or
+// the platform's equivalent.
+func geoDBFileName() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hebcal", "locations.gob"), nil
+}
+
+// loadGeoDB reads the location cache. A missing cache returns an empty
+// database and an error satisfying os.IsNotExist.
+func loadGeoDB() (*geoDB, error) {
+	db := &geoDB{Cities: make(map[int64]geoPlace), Zips: make(map[string]geoPlace)}
+	filename, err := geoDBFileName()
+	if err != nil {
+		return db, err
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return db, err
+	}
+	defer f.Close()
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(db); err != nil {
+		return db, fmt.Errorf("%s: %v", filename, err)
+	}
+	return db, nil
+}
+
+func (db *geoDB) save() (string, error) {
+	filename, err := geoDBFileName()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return "", err
+	}
+	tmpName := filename + ".tmp"
+	f, err := os.Create(tmpName)
+	if err != nil {
+		return "", err
+	}
+	w := bufio.NewWriter(f)
+	if err := gob.NewEncoder(w).Encode(db); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return filename, os.Rename(tmpName, filename)
+}
+
+// importGeoNames reads a GeoNames dump such as cities15000.txt: one
+// tab-separated line per city, with the id in column 1, the name in
+// column 2, coordinates in columns 5 and 6, the country in column 9,
+// the population in column 15, the elevation in column 16 (or the
+// digital elevation model in column 17) and the time zone in column 18.
+func (db *geoDB) importGeoNames(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	n := 0
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 18 {
+			return n, fmt.Errorf("line %d: expected 19 tab-separated columns", lineNumber)
+		}
+		id, err1 := strconv.ParseInt(fields[0], 10, 64)
+		lat, err2 := strconv.ParseFloat(fields[4], 64)
+		lon, err3 := strconv.ParseFloat(fields[5], 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			return n, fmt.Errorf("line %d: invalid id or coordinates", lineNumber)
+		}
+		population, _ := strconv.Atoi(fields[14])
+		elevation, err := strconv.ParseFloat(fields[15], 64)
+		if err != nil {
+			elevation, _ = strconv.ParseFloat(fields[16], 64)
+		}
+		db.Cities[id] = geoPlace{
+			Name:        fields[1],
+			Admin1:      fields[10],
+			CountryCode: fields[8],
+			Latitude:    lat,
+			Longitude:   lon,
+			TimeZoneId:  fields[17],
+			Population:  population,
+			Elevation:   math.Max(elevation, 0), // the model has -9999 for the sea
+		}
+		n++
+	}
+	return n, scanner.Err()
+}
+
+// zipColumns are the header names accepted for each column of a ZIP
+// code CSV file
+var zipColumns = map[string][]string{
+	"zip":       {"zip", "zipcode", "zip_code", "postal_code", "postal code"},
+	"lat":       {"lat", "latitude"},
+	"lon":       {"lng", "lon", "long", "longitude"},
+	"city":      {"city", "primary_city", "place_name", "place name"},
+	"state":     {"state_id", "state", "state_code"},
+	"timezone":  {"timezone", "time_zone", "tz", "tzid"},
+	"elevation": {"elevation", "elevation_m", "elev"},
+}
+
+// importZipCSV reads a US ZIP code CSV file with a header row, such as
+// uszips.csv or zip_code_database.csv. Columns are found by name. If
+// there is no time zone column, each ZIP code takes the time zone of
+// the nearest city in the GeoNames data imported previously.
+func (db *geoDB) importZipCSV(r io.Reader) (int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return 0, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for key, names := range zipColumns {
+			for _, candidate := range names {
+				if _, found := columns[key]; !found && name == candidate {
+					columns[key] = i
+				}
+			}
+		}
+	}
+	for _, key := range []string{"zip", "lat", "lon"} {
+		if _, ok := columns[key]; !ok {
+			return 0, fmt.Errorf("no %s column in header", key)
+		}
+	}
+	tzIdx, hasTz := columns["timezone"]
+	if !hasTz && len(db.Cities) == 0 {
+		return 0, errors.New("no timezone column; import a GeoNames cities file first")
+	}
+	grid := newGeoGrid(db.Cities, "US")
+	field := func(record []string, key string) string {
+		if i, ok := columns[key]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	n := 0
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return n, err
+		}
+		zip := field(record, "zip")
+		lat, err1 := strconv.ParseFloat(field(record, "lat"), 64)
+		lon, err2 := strconv.ParseFloat(field(record, "lon"), 64)
+		if zip == "" || err1 != nil || err2 != nil {
+			return n, fmt.Errorf("record %d: invalid ZIP code or coordinates", n+1)
+		}
+		if len(zip) < 5 {
+			zip = strings.Repeat("0", 5-len(zip)) + zip
+		}
+		place := geoPlace{
+			Name:        field(record, "city"),
+			Admin1:      field(record, "state"),
+			CountryCode: "US",
+			Latitude:    lat,
+			Longitude:   lon,
+		}
+		if elevation, err := strconv.ParseFloat(field(record, "elevation"), 64); err == nil && elevation > 0 {
+			place.Elevation = elevation
+		}
+		if hasTz && tzIdx < len(record) && record[tzIdx] != "" {
+			place.TimeZoneId = strings.TrimSpace(record[tzIdx])
+		} else if nearest, ok := grid.nearest(lat, lon); ok {
+			place.TimeZoneId = nearest.TimeZoneId
+		}
+		db.Zips[zip] = place
+		n++
+	}
+	return n, nil
+}
+
+// importGeoNamesPostal reads the US entries of a GeoNames postal code
+// dump such as US.txt, taking the time zone of the nearest city.
+func (db *geoDB) importGeoNamesPostal(r io.Reader) (int, error) {
+	if len(db.Cities) == 0 {
+		return 0, errors.New("import a GeoNames cities file first, for time zones")
+	}
+	grid := newGeoGrid(db.Cities, "US")
+	scanner := bufio.NewScanner(r)
+	n := 0
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 11 {
+			return n, fmt.Errorf("line %d: expected 12 tab-separated columns", lineNumber)
+		}
+		lat, err1 := strconv.ParseFloat(fields[9], 64)
+		lon, err2 := strconv.ParseFloat(fields[10], 64)
+		if err1 != nil || err2 != nil {
+			return n, fmt.Errorf("line %d: invalid coordinates", lineNumber)
+		}
+		if fields[0] != "US" {
+			continue
+		}
+		place := geoPlace{
+			Name:        fields[2],
+			Admin1:      fields[4],
+			CountryCode: fields[0],
+			Latitude:    lat,
+			Longitude:   lon,
+		}
+		if nearest, ok := grid.nearest(lat, lon); ok {
+			place.TimeZoneId = nearest.TimeZoneId
+		}
+		db.Zips[fields[1]] = place
+		n++
+	}
+	return n, scanner.Err()
+}
+
+// geoGrid buckets the cities of one country by whole degrees of
+// latitude and longitude, for finding the nearest city quickly.
+type geoGrid map[[2]int][]geoPlace
+
+func newGeoGrid(cities map[int64]geoPlace, countryCode string) geoGrid {
+	grid := make(geoGrid)
+	for _, city := range cities {
+		if city.CountryCode == countryCode {
+			cell := [2]int{int(math.Floor(city.Latitude)), int(math.Floor(city.Longitude))}
+			grid[cell] = append(grid[cell], city)
+		}
+	}
+	return grid
+}
+
+// nearest searches rings of cells around lat,lon until it finds a
+// city, and then one more ring in case a closer city lies just outside.
+func (grid geoGrid) nearest(lat, lon float64) (geoPlace, bool) {
+	if len(grid) == 0 {
+		return geoPlace{}, false
+	}
+	lat0, lon0 := int(math.Floor(lat)), int(math.Floor(lon))
+	best := math.Inf(1)
+	var nearest geoPlace
+	foundRing := -1
+	for r := 0; r <= 360 && (foundRing == -1 || r <= foundRing+1); r++ {
+		for dy := -r; dy <= r; dy++ {
+			for dx := -r; dx <= r; dx++ {
+				if intAbs(dy) != r && intAbs(dx) != r {
+					continue
+				}
+				for _, city := range grid[[2]int{lat0 + dy, lon0 + dx}] {
+					if d := distanceKm(lat, lon, city.Latitude, city.Longitude); d < best {
+						best, nearest = d, city
+						if foundRing == -1 {
+							foundRing = r
+						}
+					}
+				}
+			}
+		}
+	}
+	return nearest, foundRing != -1
+}
+
+// distanceKm is the great-circle distance between two points.
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	rad := math.Pi / 180
+	dlat := (lat2 - lat1) * rad
+	dlon := (lon2 - lon1) * rad
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// importGeoFile detects the format of one file and imports it.
+func (db *geoDB) importGeoFile(filename string) (int, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	first, err := br.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return 0, err
+	}
+	firstLine := string(first)
+	if i := strings.IndexByte(firstLine, '\n'); i != -1 {
+		firstLine = firstLine[:i]
+	}
+	switch tabs := strings.Count(firstLine, "\t"); {
+	case tabs >= 17:
+		return db.importGeoNames(br)
+	case tabs >= 10:
+		return db.importGeoNamesPostal(br)
+	}
+	return db.importZipCSV(br)
+}
+
+// runGeoImport implements "hebcal geo-import FILE...". GeoNames city
+// files should be given before ZIP code files without time zones.
+func runGeoImport(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: hebcal geo-import FILE...\n")
+		os.Exit(1)
+	}
+	db, err := loadGeoDB()
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	for _, filename := range args {
+		n, err := db.importGeoFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %d locations\n", filename, n)
+	}
+	filename, err := db.save()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: %d cities, %d ZIP codes\n", filename, len(db.Cities), len(db.Zips))
+	os.Exit(0)
+}
+
+// observer converts a place to a zmanim.Location with its elevation,
+// checking its time zone.
+func (place geoPlace) observer() (observer, error) {
+	if place.TimeZoneId == "" {
+		return observer{}, fmt.Errorf("no time zone for %s", place.Name)
+	}
+	if _, err := time.LoadLocation(place.TimeZoneId); err != nil {
+		return observer{}, err
+	}
+	name := place.Name
+	if place.Admin1 != "" && place.CountryCode == "US" {
+		name += ", " + place.Admin1
+	}
+	loc := zmanim.NewLocation(name, place.CountryCode, place.Latitude, place.Longitude, place.TimeZoneId)
+	return observer{Location: &loc, Elevation: place.Elevation}, nil
+}
+
+// lookupZip returns the location of a US ZIP code from the cache.
+func lookupZip(zip string) (observer, error) {
+	db, err := loadGeoDB()
+	if err != nil {
+		return observer{}, geoDBError(err)
+	}
+	place, ok := db.Zips[zip]
+	if !ok {
+		return observer{}, fmt.Errorf("unknown ZIP code: %s", zip)
+	}
+	return place.observer()
+}
+
+// lookupGeonameid returns the location of a GeoNames id from the cache.
+func lookupGeonameid(id int64) (observer, error) {
+	db, err := loadGeoDB()
+	if err != nil {
+		return observer{}, geoDBError(err)
+	}
+	place, ok := db.Cities[id]
+	if !ok {
+		return observer{}, fmt.Errorf("unknown geonameid: %d", id)
+	}
+	return place.observer()
+}
+
+func geoDBError(err error) error {
+	if os.IsNotExist(err) {
+		return errors.New("no location database; import one with 'hebcal geo-import cities15000.txt uszips.csv'")
+	}
+	return err
+}
@@ -3,8 +3,10 @@ module github.com/hebcal/hebcal
 go 1.13
 
 require (
+	github.com/hebcal/gematriya v1.0.1
 	github.com/hebcal/greg v1.0.0
 	github.com/hebcal/hdate v1.1.0
 	github.com/hebcal/hebcal-go v0.9.31
+	github.com/nathan-osman/go-sunrise v1.1.0
 	github.com/pborman/getopt/v2 v2.1.0
 )
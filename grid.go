@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hebcal/gematriya"
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+)
+
+const gridCellWidth = 7
+
+const (
+	ansiReset   = "\033[0m"
+	ansiChag    = "\033[1;31m" // bold red
+	ansiHoliday = "\033[33m"   // yellow
+	ansiRoshCh  = "\033[36m"   // cyan
+	ansiShabbat = "\033[1m"    // bold
+)
+
+// maskGridHoliday are the non-chag events that get highlighted in the grid
+const maskGridHoliday = event.MINOR_HOLIDAY | event.MODERN_HOLIDAY |
+	event.MAJOR_FAST | event.MINOR_FAST | event.CHOL_HAMOED
+
+// isTerminal returns true if f is a character device such as a TTY.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return (fi.Mode() & os.ModeCharDevice) != 0
+}
+
+// displayWidth counts the columns str occupies on a terminal,
+// ignoring combining marks such as Hebrew nikud.
+func displayWidth(str string) int {
+	n := 0
+	for _, r := range str {
+		if !unicode.Is(unicode.Mn, r) {
+			n++
+		}
+	}
+	return n
+}
+
+func padRight(str string, width int) string {
+	if n := displayWidth(str); n < width {
+		return str + strings.Repeat(" ", width-n)
+	}
+	return str
+}
+
+func center(str string, width int) string {
+	n := displayWidth(str)
+	if n >= width {
+		return str
+	}
+	return strings.Repeat(" ", (width-n)/2) + str
+}
+
+// gridDayColor picks the highlight for one day: chag, then other
+// holidays, then Rosh Chodesh, then Shabbat.
+func gridDayColor(hd hdate.HDate, events []event.CalEvent) string {
+	var flags event.HolidayFlags
+	for _, ev := range events {
+		if _, ok := ev.(hebcal.TimedEvent); !ok {
+			flags |= ev.GetFlags()
+		}
+	}
+	switch {
+	case (flags & event.CHAG) != 0:
+		return ansiChag
+	case (flags & maskGridHoliday) != 0:
+		return ansiHoliday
+	case (flags & event.ROSH_CHODESH) != 0:
+		return ansiRoshCh
+	case hd.Weekday() == time.Saturday:
+		return ansiShabbat
+	}
+	return ""
+}
+
+// writeGrid prints each month in the range as a cal(1)-style grid,
+// followed by a list of that month's events.
+func writeGrid(out io.Writer, events []event.CalEvent, calOptions *hebcal.CalOptions, color bool) error {
+	w := bufio.NewWriter(out)
+	start, end := calendarRange(calOptions)
+	hebrew := calOptions.IsHebrewYear
+	days := eventsByDay(events)
+	width := 7 * gridCellWidth
+	for i, span := range calendarMonths(start, end, hebrew) {
+		if i != 0 {
+			fmt.Fprintln(w)
+		}
+		title, subtitle := monthTitles(span, hebrew)
+		fmt.Fprintln(w, center(title, width))
+		fmt.Fprintln(w, center(subtitle, width))
+		for dow := time.Sunday; dow <= time.Saturday; dow++ {
+			name := dow.String()[0:3]
+			if isHebrewLocale(lang) {
+				name = heWeekdayNames[dow]
+			}
+			fmt.Fprint(w, padRight(name, gridCellWidth))
+		}
+		fmt.Fprintln(w)
+		fmt.Fprint(w, strings.Repeat(" ", int(span.first.Weekday())*gridCellWidth))
+		for hd := span.first; hd.Abs() <= span.last.Abs(); hd = hd.Next() {
+			_, _, gd := hd.Greg()
+			primary, other := strconv.Itoa(gd), gematriya.Gematriya(hd.Day())
+			if hebrew {
+				primary, other = other, primary
+			}
+			cell := padRight(fmt.Sprintf("%3s", primary), 3)
+			if color {
+				if c := gridDayColor(hd, days[hd.Abs()]); c != "" {
+					cell = c + cell + ansiReset
+				}
+			}
+			fmt.Fprint(w, cell+" "+padRight(other, gridCellWidth-4))
+			if hd.Weekday() == time.Saturday {
+				fmt.Fprintln(w)
+			}
+		}
+		if span.last.Weekday() != time.Saturday {
+			fmt.Fprintln(w)
+		}
+		writeGridEvents(w, span, hebrew, days)
+	}
+	return w.Flush()
+}
+
+func writeGridEvents(w io.Writer, span monthSpan, hebrew bool, days map[int64][]event.CalEvent) {
+	first := true
+	for hd := span.first; hd.Abs() <= span.last.Abs(); hd = hd.Next() {
+		for _, ev := range days[hd.Abs()] {
+			if ev.GetFlags() == event.HEBREW_DATE {
+				continue
+			}
+			if first {
+				fmt.Fprintln(w)
+				first = false
+			}
+			_, _, gd := hd.Greg()
+			day := strconv.Itoa(gd)
+			if hebrew {
+				day = gematriya.Gematriya(hd.Day())
+			}
+			fmt.Fprintf(w, "%4s  %s\n", day, ev.Render(lang))
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+	"github.com/hebcal/hebcal-go/locales"
+	"github.com/hebcal/hebcal-go/sedra"
+	"github.com/hebcal/hebcal/haftarah"
+)
+
+// haftarahEvent is the haftarah read on a Shabbat or holiday.
+type haftarahEvent struct {
+	Date     hdate.HDate
+	Occasion string // a special Shabbat or holiday, if any
+	Service  string // "Mincha" for an afternoon haftarah
+	Reading  string
+}
+
+func (ev haftarahEvent) GetDate() hdate.HDate {
+	return ev.Date
+}
+
+// haftarahHebrew has the Hebrew for the words of a haftarah title that
+// hebcal-go's locales don't translate
+var haftarahHebrew = map[string]string{
+	"Haftarah":                    "הַפְטָרָה",
+	"Mincha":                      "מִנְחָה",
+	"Shabbat Chanukah":            "שַׁבָּת חֲנוּכָּה",
+	"Shabbat Chanukah II":         "שַׁבָּת חֲנוּכָּה ב׳",
+	"Shabbat Chol ha-Moed Sukkot": "שַׁבָּת חוֹל הַמּוֹעֵד סוּכּוֹת",
+	"Shabbat Chol ha-Moed Pesach": "שַׁבָּת חוֹל הַמּוֹעֵד פֶּסַח",
+}
+
+func translateHaftarah(str, locale string) string {
+	if he, ok := haftarahHebrew[str]; ok && isHebrewLocale(locale) {
+		if locale == "he-x-nonikud" {
+			return locales.HebrewStripNikkud(he)
+		}
+		return he
+	}
+	if tr, ok := locales.LookupTranslation(str, locale); ok && tr != "" {
+		return tr
+	}
+	return str
+}
+
+// translateReading translates the book names in a reading such as
+// "Hosea 14:2-10, Joel 2:15-27" or "Isaiah 6:1-7:6, 9:5-6".
+func translateReading(reading, locale string) string {
+	parts := strings.Split(reading, ", ")
+	for i, part := range parts {
+		if sp := strings.LastIndex(part, " "); sp != -1 {
+			parts[i] = translateHaftarah(part[:sp], locale) + part[sp:]
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (ev haftarahEvent) Render(locale string) string {
+	locale = strings.ToLower(locale)
+	desc := translateHaftarah("Haftarah", locale)
+	if ev.Occasion != "" {
+		if isHebrewLocale(locale) {
+			desc += " " + translateHaftarah(ev.Occasion, locale)
+		} else {
+			desc += " for " + translateHaftarah(ev.Occasion, locale)
+		}
+	}
+	if ev.Service != "" {
+		desc += " (" + translateHaftarah(ev.Service, locale) + ")"
+	}
+	return desc + ": " + translateReading(ev.Reading, locale)
+}
+
+func (ev haftarahEvent) GetFlags() event.HolidayFlags {
+	return HAFTARAH
+}
+
+func (ev haftarahEvent) GetEmoji() string {
+	return ""
+}
+
+func (ev haftarahEvent) Basename() string {
+	return ev.Render("en")
+}
+
+// addHaftarot adds the haftarah after each Shabbat parsha, and after
+// each holiday or fast with a haftarah of its own.
+func addHaftarot(events []event.CalEvent, minhag string, calOptions *hebcal.CalOptions) []event.CalEvent {
+	result := make([]event.CalEvent, 0, len(events)+60)
+	sedraYears := make(map[int]sedra.Sedra)
+	done := make(map[int64]bool)
+	holidayNamed := make(map[int64]map[string]bool)
+	for _, ev := range events {
+		if holidayEv, ok := ev.(event.HolidayEvent); ok {
+			abs := holidayEv.Date.Abs()
+			if holidayNamed[abs] == nil {
+				holidayNamed[abs] = make(map[string]bool)
+			}
+			holidayNamed[abs][holidayEv.Desc] = true
+		}
+	}
+	for _, ev := range events {
+		result = append(result, ev)
+		hd := ev.GetDate()
+		abs := hd.Abs()
+		if done[abs] {
+			continue
+		}
+		if ev.GetFlags() == event.PARSHA_HASHAVUA && hd.Weekday() == time.Saturday {
+			sy, ok := sedraYears[hd.Year()]
+			if !ok {
+				sy = sedra.New(hd.Year(), calOptions.IL)
+				sedraYears[hd.Year()] = sy
+			}
+			occasion, reading := haftarah.ForShabbat(hd, sy.LookupByRD(abs))
+			if r := reading.ForMinhag(minhag); r != "" {
+				result = append(result, haftarahEvent{Date: hd, Occasion: occasion, Reading: r})
+			}
+			done[abs] = true
+			continue
+		}
+		holidayEv, ok := ev.(event.HolidayEvent)
+		if !ok {
+			continue
+		}
+		if haftarot := haftarah.ForHoliday(hd, calOptions.IL); len(haftarot) != 0 {
+			// e.g. Ta'anit Esther on the same day as Erev Purim
+			if occasion := haftarot[0].Occasion; holidayEv.Desc != occasion && holidayNamed[abs][occasion] {
+				continue
+			}
+			for _, h := range haftarot {
+				if r := h.Reading.ForMinhag(minhag); r != "" {
+					result = append(result, haftarahEvent{Date: hd, Occasion: h.Occasion, Service: h.Service, Reading: r})
+				}
+			}
+			done[abs] = true
+		}
+	}
+	return result
+}
@@ -0,0 +1,345 @@
+// Package haftarah has the haftarot read on Shabbat, holidays and
+// fasts in the Ashkenazi, Sephardi, Chabad and Yemenite customs, for use
+// with the weekly parsha from package sedra.
+package haftarah
+
+import (
+	"time"
+
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/sedra"
+)
+
+// Minhagim are the customs with haftarot in this package
+var Minhagim = []string{"ashkenazi", "sephardi", "chabad", "yemenite"}
+
+// ValidMinhag returns true if minhag is one of Minhagim.
+func ValidMinhag(minhag string) bool {
+	for _, m := range Minhagim {
+		if m == minhag {
+			return true
+		}
+	}
+	return false
+}
+
+// Reading is a haftarah in each custom. The ashkenazi reading
+// is always given; an empty sephardi or chabad reading means that
+// custom reads the same haftarah, as it does for most holidays and
+// special Shabbatot. The Yemenite (Baladi) custom follows the Rambam,
+// which mostly agrees with the Sephardim, so an empty yemenite reading
+// means the sephardi one. noHaftarah means that custom reads none.
+type Reading struct {
+	Ashkenazi string
+	Sephardi  string
+	Chabad    string
+	Yemenite  string
+}
+
+const noHaftarah = "-"
+
+// ForMinhag returns the reading in minhag, or "" if there is none.
+func (r Reading) ForMinhag(minhag string) string {
+	switch {
+	case minhag == "yemenite" && r.Yemenite == noHaftarah:
+		return ""
+	case minhag == "yemenite" && r.Yemenite != "":
+		return r.Yemenite
+	case minhag == "yemenite":
+		return r.ForMinhag("sephardi")
+	case minhag == "sephardi" && r.Sephardi == noHaftarah:
+		return ""
+	case minhag == "sephardi" && r.Sephardi != "":
+		return r.Sephardi
+	case minhag == "chabad" && r.Chabad != "":
+		return r.Chabad
+	}
+	return r.Ashkenazi
+}
+
+// parshaHaftarot are the haftarot of the weekly parshiyot, indexed
+// like sedra.Parsha.Num
+var parshaHaftarot = []Reading{
+	// Bereshit
+	{Ashkenazi: "Isaiah 42:5-43:10", Sephardi: "Isaiah 42:5-21", Chabad: "Isaiah 42:5-21", Yemenite: "Isaiah 42:1-16"},
+	// Noach
+	{Ashkenazi: "Isaiah 54:1-55:5", Sephardi: "Isaiah 54:1-10"},
+	// Lech-Lecha
+	{Ashkenazi: "Isaiah 40:27-41:16"},
+	// Vayera
+	{Ashkenazi: "II Kings 4:1-37", Sephardi: "II Kings 4:1-23", Chabad: "II Kings 4:1-23"},
+	// Chayei Sara
+	{Ashkenazi: "I Kings 1:1-31"},
+	// Toldot
+	{Ashkenazi: "Malachi 1:1-2:7"},
+	// Vayetzei
+	{Ashkenazi: "Hosea 12:13-14:10", Sephardi: "Hosea 11:7-12:12", Chabad: "Hosea 11:7-12:12"},
+	// Vayishlach
+	{Ashkenazi: "Hosea 11:7-12:12", Sephardi: "Obadiah 1:1-21", Chabad: "Obadiah 1:1-21"},
+	// Vayeshev
+	{Ashkenazi: "Amos 2:6-3:8"},
+	// Miketz
+	{Ashkenazi: "I Kings 3:15-4:1"},
+	// Vayigash
+	{Ashkenazi: "Ezekiel 37:15-28"},
+	// Vayechi
+	{Ashkenazi: "I Kings 2:1-12"},
+	// Shemot
+	{Ashkenazi: "Isaiah 27:6-28:13, 29:22-23", Sephardi: "Jeremiah 1:1-2:3", Yemenite: "Ezekiel 16:1-14"},
+	// Vaera
+	{Ashkenazi: "Ezekiel 28:25-29:21"},
+	// Bo
+	{Ashkenazi: "Jeremiah 46:13-28"},
+	// Beshalach
+	{Ashkenazi: "Judges 4:4-5:31", Sephardi: "Judges 5:1-31"},
+	// Yitro
+	{Ashkenazi: "Isaiah 6:1-7:6, 9:5-6", Sephardi: "Isaiah 6:1-13"},
+	// Mishpatim
+	{Ashkenazi: "Jeremiah 34:8-22, 33:25-26"},
+	// Terumah
+	{Ashkenazi: "I Kings 5:26-6:13"},
+	// Tetzaveh
+	{Ashkenazi: "Ezekiel 43:10-27"},
+	// Ki Tisa
+	{Ashkenazi: "I Kings 18:1-39", Sephardi: "I Kings 18:20-39"},
+	// Vayakhel
+	{Ashkenazi: "I Kings 7:40-50", Sephardi: "I Kings 7:13-26", Chabad: "I Kings 7:13-26"},
+	// Pekudei
+	{Ashkenazi: "I Kings 7:51-8:21", Sephardi: "I Kings 7:40-50", Chabad: "I Kings 7:40-50"},
+	// Vayikra
+	{Ashkenazi: "Isaiah 43:21-44:23"},
+	// Tzav
+	{Ashkenazi: "Jeremiah 7:21-8:3, 9:22-23"},
+	// Shmini
+	{Ashkenazi: "II Samuel 6:1-7:17", Sephardi: "II Samuel 6:1-19", Chabad: "II Samuel 6:1-19"},
+	// Tazria
+	{Ashkenazi: "II Kings 4:42-5:19"},
+	// Metzora
+	{Ashkenazi: "II Kings 7:3-20"},
+	// Achrei Mot
+	{Ashkenazi: "Ezekiel 22:1-19", Sephardi: "Ezekiel 22:1-16", Chabad: "Ezekiel 22:1-16"},
+	// Kedoshim
+	{Ashkenazi: "Amos 9:7-15", Sephardi: "Ezekiel 20:2-20"},
+	// Emor
+	{Ashkenazi: "Ezekiel 44:15-31"},
+	// Behar
+	{Ashkenazi: "Jeremiah 32:6-27"},
+	// Bechukotai
+	{Ashkenazi: "Jeremiah 16:19-17:14"},
+	// Bamidbar
+	{Ashkenazi: "Hosea 2:1-22"},
+	// Nasso
+	{Ashkenazi: "Judges 13:2-25"},
+	// Beha'alotcha
+	{Ashkenazi: "Zechariah 2:14-4:7"},
+	// Sh'lach
+	{Ashkenazi: "Joshua 2:1-24"},
+	// Korach
+	{Ashkenazi: "I Samuel 11:14-12:22"},
+	// Chukat
+	{Ashkenazi: "Judges 11:1-33"},
+	// Balak
+	{Ashkenazi: "Micah 5:6-6:8"},
+	// Pinchas
+	{Ashkenazi: "I Kings 18:46-19:21"},
+	// Matot
+	{Ashkenazi: "Jeremiah 1:1-2:3"},
+	// Masei
+	{Ashkenazi: "Jeremiah 2:4-28, 3:4", Sephardi: "Jeremiah 2:4-28, 4:1-2", Chabad: "Jeremiah 2:4-28, 4:1-2"},
+	// Devarim
+	{Ashkenazi: "Isaiah 1:1-27"},
+	// Vaetchanan
+	{Ashkenazi: "Isaiah 40:1-26"},
+	// Eikev
+	{Ashkenazi: "Isaiah 49:14-51:3"},
+	// Re'eh
+	{Ashkenazi: "Isaiah 54:11-55:5"},
+	// Shoftim
+	{Ashkenazi: "Isaiah 51:12-52:12"},
+	// Ki Teitzei
+	{Ashkenazi: "Isaiah 54:1-10"},
+	// Ki Tavo
+	{Ashkenazi: "Isaiah 60:1-22"},
+	// Nitzavim
+	{Ashkenazi: "Isaiah 61:10-63:9"},
+	// Vayeilech
+	{Ashkenazi: "Isaiah 55:6-56:8"},
+	// Ha'azinu
+	{Ashkenazi: "II Samuel 22:1-51"},
+}
+
+// Haftarot that replace the parsha's on special Shabbatot
+var (
+	roshChodesh   = Reading{Ashkenazi: "Isaiah 66:1-24"}
+	macharChodesh = Reading{Ashkenazi: "I Samuel 20:18-42"}
+	shekalim      = Reading{Ashkenazi: "II Kings 12:1-17", Sephardi: "II Kings 11:17-12:17", Chabad: "II Kings 11:17-12:17"}
+	zachor        = Reading{Ashkenazi: "I Samuel 15:2-34", Sephardi: "I Samuel 15:1-34"}
+	parah         = Reading{Ashkenazi: "Ezekiel 36:16-38", Sephardi: "Ezekiel 36:16-36"}
+	haChodesh     = Reading{Ashkenazi: "Ezekiel 45:16-46:18", Sephardi: "Ezekiel 45:18-46:15"}
+	haGadol       = Reading{Ashkenazi: "Malachi 3:4-24"}
+	shuva         = Reading{Ashkenazi: "Hosea 14:2-10, Joel 2:15-27", Sephardi: "Hosea 14:2-10, Micah 7:18-20", Chabad: "Hosea 14:2-10, Micah 7:18-20"}
+	chanukah      = Reading{Ashkenazi: "Zechariah 2:14-4:7"}
+	chanukah2     = Reading{Ashkenazi: "I Kings 7:40-50"}
+)
+
+// fastMincha is read at Mincha of a public fast by Ashkenazim and,
+// following the Rambam, by Yemenites; Sephardim read a haftarah at
+// Mincha only on Tish'a B'Av.
+var fastMincha = Reading{Ashkenazi: "Isaiah 55:6-56:8", Sephardi: noHaftarah, Yemenite: "Isaiah 55:6-56:8"}
+
+// haftarotOfAffliction are read on the three Shabbatot before Tish'a B'Av
+var haftarotOfAffliction = []Reading{
+	parshaHaftarot[41], // Matot
+	parshaHaftarot[42], // Masei
+	parshaHaftarot[43], // Devarim
+}
+
+// haftarotOfConsolation are read on the seven Shabbatot after Tish'a B'Av
+var haftarotOfConsolation = []Reading{
+	parshaHaftarot[44], // Vaetchanan
+	parshaHaftarot[45], // Eikev
+	parshaHaftarot[46], // Re'eh
+	parshaHaftarot[47], // Shoftim
+	parshaHaftarot[48], // Ki Teitzei
+	parshaHaftarot[49], // Ki Tavo
+	parshaHaftarot[50], // Nitzavim
+}
+
+// Holiday is a haftarah read on a holiday, which is Shacharit
+// unless service says otherwise.
+type Holiday struct {
+	Occasion string
+	Service  string
+	Reading  Reading
+}
+
+// ForHoliday returns the haftarot read on hd if it is a holiday
+// or a fast. A fast that falls on Shabbat is postponed to Sunday,
+// except Ta'anit Esther, which is moved back to Thursday.
+func ForHoliday(hd hdate.HDate, il bool) []Holiday {
+	day := hd.Day()
+	shabbat := hd.Weekday() == time.Saturday
+	sunday := hd.Weekday() == time.Sunday
+	switch hd.Month() {
+	case hdate.Tishrei:
+		switch {
+		case (day == 3 && !shabbat) || (day == 4 && sunday):
+			return []Holiday{{"Tzom Gedaliah", "Mincha", fastMincha}}
+		case day == 1:
+			return []Holiday{{"Rosh Hashana I", "", Reading{Ashkenazi: "I Samuel 1:1-2:10"}}}
+		case day == 2:
+			return []Holiday{{"Rosh Hashana II", "", Reading{Ashkenazi: "Jeremiah 31:1-19"}}}
+		case day == 10:
+			return []Holiday{
+				{"Yom Kippur", "", Reading{Ashkenazi: "Isaiah 57:14-58:14"}},
+				{"Yom Kippur", "Mincha", Reading{Ashkenazi: "Jonah 1:1-4:11, Micah 7:18-20"}},
+			}
+		case day == 15:
+			return []Holiday{{"Sukkot I", "", Reading{Ashkenazi: "Zechariah 14:1-21"}}}
+		case day == 16 && !il:
+			return []Holiday{{"Sukkot II", "", Reading{Ashkenazi: "I Kings 8:2-21"}}}
+		case day >= 16 && day <= 21 && shabbat:
+			return []Holiday{{"Shabbat Chol ha-Moed Sukkot", "", Reading{Ashkenazi: "Ezekiel 38:18-39:16"}}}
+		case day == 22 && !il:
+			return []Holiday{{"Shmini Atzeret", "", Reading{Ashkenazi: "I Kings 8:54-66", Sephardi: "I Kings 8:54-9:1"}}}
+		case (day == 22 && il) || (day == 23 && !il):
+			return []Holiday{{"Simchat Torah", "", Reading{Ashkenazi: "Joshua 1:1-18", Sephardi: "Joshua 1:1-9"}}}
+		}
+	case hdate.Tevet:
+		if day == 10 {
+			return []Holiday{{"Asara B'Tevet", "Mincha", fastMincha}}
+		}
+	case hdate.Adar1, hdate.Adar2:
+		if hd.Month() == adarOf(hd.Year()) &&
+			((day == 13 && !shabbat) || (day == 11 && hd.Weekday() == time.Thursday)) {
+			return []Holiday{{"Ta'anit Esther", "Mincha", fastMincha}}
+		}
+	case hdate.Nisan:
+		switch {
+		case day == 15:
+			return []Holiday{{"Pesach I", "", Reading{Ashkenazi: "Joshua 3:5-7, 5:2-6:1, 6:27", Sephardi: "Joshua 5:2-6:1, 6:27"}}}
+		case day == 16 && !il:
+			return []Holiday{{"Pesach II", "", Reading{Ashkenazi: "II Kings 23:1-9, 23:21-25"}}}
+		case day >= 16 && day <= 20 && shabbat:
+			return []Holiday{{"Shabbat Chol ha-Moed Pesach", "", Reading{Ashkenazi: "Ezekiel 37:1-14"}}}
+		case day == 21:
+			return []Holiday{{"Pesach VII", "", Reading{Ashkenazi: "II Samuel 22:1-51"}}}
+		case day == 22 && !il:
+			return []Holiday{{"Pesach VIII", "", Reading{Ashkenazi: "Isaiah 10:32-12:6"}}}
+		}
+	case hdate.Sivan:
+		switch {
+		case day == 6:
+			return []Holiday{{"Shavuot I", "", Reading{Ashkenazi: "Ezekiel 1:1-28, 3:12"}}}
+		case day == 7 && !il:
+			return []Holiday{{"Shavuot II", "", Reading{Ashkenazi: "Habakkuk 2:20-3:19", Sephardi: "Habakkuk 3:1-19"}}}
+		}
+	case hdate.Tamuz:
+		if (day == 17 && !shabbat) || (day == 18 && sunday) {
+			return []Holiday{{"Tzom Tammuz", "Mincha", fastMincha}}
+		}
+	case hdate.Av:
+		if (day == 9 && !shabbat) || (day == 10 && sunday) {
+			return []Holiday{
+				{"Tish'a B'Av", "", Reading{Ashkenazi: "Jeremiah 8:13-9:23"}},
+				{"Tish'a B'Av", "Mincha", Reading{Ashkenazi: "Isaiah 55:6-56:8", Sephardi: "Hosea 14:2-10, Micah 7:18-20"}},
+			}
+		}
+	}
+	return nil
+}
+
+// adarOf returns the Adar in which Purim is celebrated in year.
+func adarOf(year int) hdate.HMonth {
+	if hdate.IsLeapYear(year) {
+		return hdate.Adar2
+	}
+	return hdate.Adar1
+}
+
+// ForShabbat returns the haftarah read on the Shabbat hd, which
+// has the weekly parsha, and the special Shabbat it is read for, if
+// any. Chanukah and the four parshiyot take precedence over Rosh
+// Chodesh, as do the Shabbatot of affliction and consolation.
+func ForShabbat(hd hdate.HDate, parsha sedra.Parsha) (string, Reading) {
+	year := hd.Year()
+	abs := hd.Abs()
+	shabbatBefore := func(month hdate.HMonth, day int) int64 {
+		return hdate.DayOnOrBefore(time.Saturday, hdate.ToRD(year, month, day))
+	}
+	chanukahDay := abs - hdate.ToRD(year, hdate.Kislev, 25) + 1
+	shabbatShekalim := shabbatBefore(adarOf(year), 1)
+	shabbatHaChodesh := shabbatBefore(hdate.Nisan, 1)
+	chazon := shabbatBefore(hdate.Av, 9)
+	switch {
+	case chanukahDay == 8:
+		return "Shabbat Chanukah II", chanukah2
+	case chanukahDay >= 1 && chanukahDay <= 7:
+		return "Shabbat Chanukah", chanukah
+	case abs == shabbatShekalim:
+		return "Shabbat Shekalim", shekalim
+	case abs == shabbatBefore(adarOf(year), 13):
+		return "Shabbat Zachor", zachor
+	case abs == shabbatHaChodesh-7:
+		return "Shabbat Parah", parah
+	case abs == shabbatHaChodesh:
+		return "Shabbat HaChodesh", haChodesh
+	case abs == shabbatBefore(hdate.Nisan, 14):
+		return "Shabbat HaGadol", haGadol
+	case abs >= chazon-14 && abs <= chazon:
+		return "", haftarotOfAffliction[2-(chazon-abs)/7]
+	case abs > chazon && abs <= chazon+49:
+		return "", haftarotOfConsolation[(abs-chazon)/7-1]
+	case hd.Month() == hdate.Tishrei && hd.Day() >= 3 && hd.Day() <= 9:
+		return "Shabbat Shuva", shuva
+	case hd.Day() == 1 || hd.Day() == 30:
+		return "Shabbat Rosh Chodesh", roshChodesh
+	case hd.Day() == 29:
+		return "Shabbat Machar Chodesh", macharChodesh
+	}
+	num := parsha.Num[len(parsha.Num)-1]
+	if parsha.Num[0] == 51 { // Nitzavim-Vayeilech
+		num = parsha.Num[0]
+	}
+	return "", parshaHaftarot[num-1]
+}
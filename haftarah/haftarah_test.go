@@ -0,0 +1,108 @@
+package haftarah
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/sedra"
+)
+
+func TestForShabbat(t *testing.T) {
+	tests := []struct {
+		date     string
+		minhag   string
+		occasion string
+		reading  string
+	}{
+		// 5784
+		{"2023-09-23", "ashkenazi", "Shabbat Shuva", "Hosea 14:2-10, Joel 2:15-27"},
+		{"2023-09-23", "sephardi", "Shabbat Shuva", "Hosea 14:2-10, Micah 7:18-20"},
+		{"2023-10-14", "ashkenazi", "Shabbat Machar Chodesh", "I Samuel 20:18-42"},
+		{"2023-10-21", "ashkenazi", "", "Isaiah 54:1-55:5"},
+		{"2023-10-21", "sephardi", "", "Isaiah 54:1-10"},
+		{"2023-10-21", "yemenite", "", "Isaiah 54:1-10"},
+		{"2023-12-09", "ashkenazi", "Shabbat Chanukah", "Zechariah 2:14-4:7"},
+		{"2024-02-10", "ashkenazi", "Shabbat Rosh Chodesh", "Isaiah 66:1-24"},
+		{"2024-03-09", "ashkenazi", "Shabbat Shekalim", "II Kings 12:1-17"},
+		{"2024-03-09", "sephardi", "Shabbat Shekalim", "II Kings 11:17-12:17"},
+		{"2024-03-09", "yemenite", "Shabbat Shekalim", "II Kings 11:17-12:17"},
+		{"2024-03-23", "ashkenazi", "Shabbat Zachor", "I Samuel 15:2-34"},
+		{"2024-03-30", "ashkenazi", "Shabbat Parah", "Ezekiel 36:16-38"},
+		{"2024-04-06", "ashkenazi", "Shabbat HaChodesh", "Ezekiel 45:16-46:18"},
+		{"2024-04-20", "ashkenazi", "Shabbat HaGadol", "Malachi 3:4-24"},
+		{"2024-07-27", "ashkenazi", "", "Jeremiah 1:1-2:3"}, // Pinchas
+		{"2024-08-03", "ashkenazi", "", "Jeremiah 2:4-28, 3:4"},
+		{"2024-08-10", "ashkenazi", "", "Isaiah 1:1-27"},
+		{"2024-08-17", "ashkenazi", "", "Isaiah 40:1-26"},
+		{"2024-09-28", "ashkenazi", "", "Isaiah 61:10-63:9"},
+		// 5785
+		{"2024-10-26", "ashkenazi", "", "Isaiah 42:5-43:10"}, // Bereshit
+		{"2024-10-26", "sephardi", "", "Isaiah 42:5-21"},
+		{"2024-10-26", "yemenite", "", "Isaiah 42:1-16"},
+		{"2025-01-18", "sephardi", "", "Jeremiah 1:1-2:3"}, // Shemot
+		{"2025-01-18", "yemenite", "", "Ezekiel 16:1-14"},
+		{"2024-12-28", "ashkenazi", "Shabbat Chanukah", "Zechariah 2:14-4:7"},
+		// Re'eh on the eve of Rosh Chodesh Elul is still consolation
+		{"2025-08-23", "ashkenazi", "", "Isaiah 54:11-55:5"},
+		// 5770 and 5787: two Shabbatot of Chanukah
+		{"2009-12-12", "ashkenazi", "Shabbat Chanukah", "Zechariah 2:14-4:7"},
+		{"2009-12-19", "ashkenazi", "Shabbat Chanukah II", "I Kings 7:40-50"},
+		{"2026-12-12", "ashkenazi", "Shabbat Chanukah II", "I Kings 7:40-50"},
+	}
+	sedraYears := make(map[int]sedra.Sedra)
+	for _, test := range tests {
+		d, err := time.Parse("2006-01-02", test.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hd := hdate.FromTime(d)
+		sy, ok := sedraYears[hd.Year()]
+		if !ok {
+			sy = sedra.New(hd.Year(), false)
+			sedraYears[hd.Year()] = sy
+		}
+		occasion, reading := ForShabbat(hd, sy.LookupByRD(hd.Abs()))
+		if got := reading.ForMinhag(test.minhag); occasion != test.occasion || got != test.reading {
+			t.Errorf("ForShabbat(%s) %s = %q, %q; want %q, %q",
+				test.date, test.minhag, occasion, got, test.occasion, test.reading)
+		}
+	}
+}
+
+func TestForHoliday(t *testing.T) {
+	tests := []struct {
+		date     string
+		minhag   string
+		occasion string
+		readings []string
+	}{
+		{"2023-09-18", "ashkenazi", "Tzom Gedaliah", []string{"Isaiah 55:6-56:8"}},
+		{"2023-09-18", "sephardi", "Tzom Gedaliah", []string{""}},
+		{"2023-09-18", "yemenite", "Tzom Gedaliah", []string{"Isaiah 55:6-56:8"}},
+		{"2024-03-21", "ashkenazi", "Ta'anit Esther", []string{"Isaiah 55:6-56:8"}}, // moved to Thursday
+		{"2024-03-23", "ashkenazi", "", nil},
+		{"2025-07-12", "ashkenazi", "", nil},
+		{"2025-07-13", "ashkenazi", "Tzom Tammuz", []string{"Isaiah 55:6-56:8"}}, // postponed to Sunday
+		{"2024-08-13", "ashkenazi", "Tish'a B'Av", []string{"Jeremiah 8:13-9:23", "Isaiah 55:6-56:8"}},
+		{"2024-08-13", "sephardi", "Tish'a B'Av", []string{"Jeremiah 8:13-9:23", "Hosea 14:2-10, Micah 7:18-20"}},
+		{"2024-08-13", "yemenite", "Tish'a B'Av", []string{"Jeremiah 8:13-9:23", "Hosea 14:2-10, Micah 7:18-20"}},
+	}
+	for _, test := range tests {
+		d, err := time.Parse("2006-01-02", test.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		haftarot := ForHoliday(hdate.FromTime(d), false)
+		if len(haftarot) != len(test.readings) {
+			t.Errorf("ForHoliday(%s) = %d haftarot; want %d", test.date, len(haftarot), len(test.readings))
+			continue
+		}
+		for i, h := range haftarot {
+			if got := h.Reading.ForMinhag(test.minhag); h.Occasion != test.occasion || got != test.readings[i] {
+				t.Errorf("ForHoliday(%s) %s [%d] = %q, %q; want %q, %q",
+					test.date, test.minhag, i, h.Occasion, got, test.occasion, test.readings[i])
+			}
+		}
+	}
+}
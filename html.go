@@ -0,0 +1,240 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/hebcal/gematriya"
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+)
+
+type htmlEvent struct {
+	Class string
+	Title string
+}
+
+type htmlCell struct {
+	Empty   bool
+	Primary string // day number in the calendar the grid is laid out by
+	Other   string // day number in the other calendar
+	Events  []htmlEvent
+}
+
+type htmlMonth struct {
+	Title    string
+	Subtitle string
+	Weeks    [][]htmlCell
+}
+
+type htmlPage struct {
+	Lang     string
+	Dir      string
+	Weekdays []string
+	Months   []htmlMonth
+}
+
+var heWeekdayNames = []string{"ראשון", "שני", "שלישי", "רביעי", "חמישי", "שישי", "שבת"}
+
+var heGregMonthNames = []string{"", "ינואר", "פברואר", "מרץ", "אפריל", "מאי", "יוני",
+	"יולי", "אוגוסט", "ספטמבר", "אוקטובר", "נובמבר", "דצמבר"}
+
+// htmlEventClass maps event flags to the CSS class used to style them.
+func htmlEventClass(flags event.HolidayFlags) string {
+	switch {
+	case (flags & event.CHAG) != 0:
+		return "chag"
+	case (flags & (event.MAJOR_FAST | event.MINOR_FAST)) != 0:
+		return "fast"
+	case (flags & event.ROSH_CHODESH) != 0:
+		return "roshchodesh"
+	case (flags & event.PARSHA_HASHAVUA) != 0:
+		return "parsha"
+	case (flags & (event.LIGHT_CANDLES | event.LIGHT_CANDLES_TZEIS |
+		event.CHANUKAH_CANDLES | event.YOM_TOV_ENDS)) != 0:
+		return "candles"
+	case (flags & event.USER_EVENT) != 0:
+		return "user"
+	}
+	return "holiday"
+}
+
+func gregMonthName(month time.Month) string {
+	if isHebrewLocale(lang) {
+		return heGregMonthNames[month]
+	}
+	return month.String()
+}
+
+// hebYearString returns the year in gematriya for Hebrew output.
+func hebYearString(year int) string {
+	if isHebrewLocale(lang) {
+		return gematriya.Gematriya(year)
+	}
+	return strconv.Itoa(year)
+}
+
+func hebDayString(hd hdate.HDate) string {
+	if isHebrewLocale(lang) {
+		return gematriya.Gematriya(hd.Day())
+	}
+	return strconv.Itoa(hd.Day())
+}
+
+// monthTitles returns a heading such as "December 2024" and a
+// subtitle naming the months of the other calendar it overlaps, such
+// as "Kislev – Tevet 5785".
+func monthTitles(span monthSpan, hebrew bool) (string, string) {
+	first, last := span.first, span.last
+	gy1, gm1, _ := first.Greg()
+	gy2, gm2, _ := last.Greg()
+	gregFirst := gregMonthName(gm1)
+	gregLast := gregMonthName(gm2) + " " + strconv.Itoa(gy2)
+	if gy1 != gy2 {
+		gregFirst += " " + strconv.Itoa(gy1)
+	}
+	hebFirst := first.MonthName(lang)
+	hebLast := last.MonthName(lang) + " " + hebYearString(last.Year())
+	if first.Year() != last.Year() {
+		hebFirst += " " + hebYearString(first.Year())
+	}
+	if hebrew {
+		title := hebFirst + " " + hebYearString(first.Year())
+		if gm1 == gm2 {
+			return title, gregLast
+		}
+		return title, gregFirst + " – " + gregLast
+	}
+	title := gregMonthName(gm1) + " " + strconv.Itoa(gy1)
+	if first.Month() == last.Month() {
+		return title, hebLast
+	}
+	return title, hebFirst + " – " + hebLast
+}
+
+// buildMonthWeeks lays out the days of span as rows of seven cells,
+// Sunday first, padded with empty cells at either end.
+func buildMonthWeeks(span monthSpan, hebrew bool, days map[int64][]event.CalEvent) [][]htmlCell {
+	weeks := make([][]htmlCell, 0, 6)
+	week := make([]htmlCell, int(span.first.Weekday()), 7)
+	for i := range week {
+		week[i].Empty = true
+	}
+	for hd := span.first; hd.Abs() <= span.last.Abs(); hd = hd.Next() {
+		_, gm, gd := hd.Greg()
+		var cell htmlCell
+		if hebrew {
+			cell.Primary, cell.Other = hebDayString(hd), strconv.Itoa(gd)
+			if gd == 1 || hd == span.first {
+				cell.Other = gregMonthName(gm) + " " + cell.Other
+			}
+		} else {
+			cell.Primary, cell.Other = strconv.Itoa(gd), hebDayString(hd)
+			if hd.Day() == 1 || hd == span.first {
+				cell.Other += " " + hd.MonthName(lang)
+			}
+		}
+		for _, ev := range days[hd.Abs()] {
+			flags := ev.GetFlags()
+			if flags == event.HEBREW_DATE {
+				continue // every cell already shows the Hebrew date
+			}
+			cell.Events = append(cell.Events, htmlEvent{
+				Class: htmlEventClass(flags),
+				Title: ev.Render(lang),
+			})
+		}
+		week = append(week, cell)
+		if len(week) == 7 {
+			weeks = append(weeks, week)
+			week = make([]htmlCell, 0, 7)
+		}
+	}
+	if len(week) != 0 {
+		for len(week) < 7 {
+			week = append(week, htmlCell{Empty: true})
+		}
+		weeks = append(weeks, week)
+	}
+	return weeks
+}
+
+// writeHTML writes a printable HTML document with one month grid per page.
+func writeHTML(out io.Writer, events []event.CalEvent, calOptions *hebcal.CalOptions) error {
+	start, end := calendarRange(calOptions)
+	hebrew := calOptions.IsHebrewYear
+	days := eventsByDay(events)
+	page := htmlPage{Lang: "en", Dir: "ltr"}
+	if isHebrewLocale(lang) {
+		page.Lang = "he"
+		page.Dir = "rtl"
+		page.Weekdays = heWeekdayNames
+	} else {
+		page.Weekdays = make([]string, 7)
+		for i := range page.Weekdays {
+			page.Weekdays[i] = time.Weekday(i).String()
+		}
+	}
+	for _, span := range calendarMonths(start, end, hebrew) {
+		title, subtitle := monthTitles(span, hebrew)
+		page.Months = append(page.Months, htmlMonth{
+			Title:    title,
+			Subtitle: subtitle,
+			Weeks:    buildMonthWeeks(span, hebrew, days),
+		})
+	}
+	return htmlTemplate.Execute(out, page)
+}
+
+var htmlTemplate = template.Must(template.New("calendar").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}" dir="{{.Dir}}">
+<head>
+<meta charset="utf-8">
+<title>Hebcal</title>
+<style>
+body { font-family: sans-serif; margin: 0; }
+.month { page-break-after: always; break-after: page; padding: 1em; }
+.month:last-child { page-break-after: auto; break-after: auto; }
+h1 { margin: 0; font-size: 1.8em; }
+h2 { margin: 0 0 0.5em; font-size: 1.1em; font-weight: normal; color: #555; }
+table { width: 100%; border-collapse: collapse; table-layout: fixed; }
+th { background: #eee; border: 1px solid #999; padding: 0.2em; }
+td { border: 1px solid #999; height: 6em; vertical-align: top; padding: 0.2em; font-size: 0.8em; }
+td.empty { background: #f7f7f7; }
+td:last-child { background: #f4f4ff; }
+.primary { font-weight: bold; font-size: 1.2em; }
+.other { float: right; color: #777; }
+[dir=rtl] .other { float: left; }
+.event { margin-top: 0.2em; }
+.chag { font-weight: bold; color: #900; }
+.fast { color: #960; }
+.roshchodesh { color: #06c; }
+.parsha { font-style: italic; }
+.candles { color: #444; }
+.user { color: #070; }
+</style>
+</head>
+<body>
+{{- range .Months}}
+<div class="month">
+<h1>{{.Title}}</h1>
+<h2>{{.Subtitle}}</h2>
+<table>
+<tr>{{range $.Weekdays}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Weeks}}
+<tr>
+{{- range .}}
+{{if .Empty}}<td class="empty"></td>{{else}}<td><span class="primary">{{.Primary}}</span> <span class="other">{{.Other}}</span>
+{{- range .Events}}<div class="event {{.Class}}">{{.Title}}</div>{{end}}</td>{{end}}
+{{- end}}
+</tr>
+{{- end}}
+</table>
+</div>
+{{- end}}
+</body>
+</html>
+`))
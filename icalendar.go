@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+)
+
+const icalDateFormat = "20060102"
+const icalDateTimeFormat = "20060102T150405"
+
+// icalWriter emits RFC 5545 content lines, folding them at 75 octets
+// and terminating them with CRLF.
+type icalWriter struct {
+	w *bufio.Writer
+}
+
+func (iw *icalWriter) writeLine(line string) {
+	limit := 75
+	for len(line) > limit {
+		// never split a multi-byte UTF-8 sequence across two lines
+		n := limit
+		for n > 0 && !utf8.RuneStart(line[n]) {
+			n--
+		}
+		iw.w.WriteString(line[:n])
+		iw.w.WriteString("\r\n ")
+		line = line[n:]
+		// continuation lines begin with a space, which counts toward the limit
+		limit = 74
+	}
+	iw.w.WriteString(line)
+	iw.w.WriteString("\r\n")
+}
+
+var icalTextEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func icalEscape(str string) string {
+	return icalTextEscaper.Replace(str)
+}
+
+// icalUID returns an identifier that stays the same between runs for
+// the same event on the same day at the same location.
+func icalUID(ev event.CalEvent, loc string) string {
+	year, month, day := ev.GetDate().Greg()
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	key := fmt.Sprintf("%s|%s|%d|%s", date.Format(icalDateFormat),
+		ev.Basename(), ev.GetFlags(), loc)
+	sum := sha1.Sum([]byte(key))
+	return fmt.Sprintf("hebcal-%s-%x", date.Format(icalDateFormat), sum[:8])
+}
+
+func writeICalendar(out io.Writer, events []event.CalEvent, calOptions *hebcal.CalOptions) error {
+	iw := icalWriter{w: bufio.NewWriter(out)}
+	var tzid, locationName string
+	var tz *time.Location
+	if calOptions.Location != nil {
+		tzid = calOptions.Location.TimeZoneId
+		locationName = calOptions.Location.Name
+		var err error
+		tz, err = time.LoadLocation(tzid)
+		if err != nil {
+			return err
+		}
+	}
+
+	iw.writeLine("BEGIN:VCALENDAR")
+	iw.writeLine("VERSION:2.0")
+	iw.writeLine("PRODID:-//hebcal.com/NONSGML Hebcal Calendar v" + Version + "//EN")
+	iw.writeLine("CALSCALE:GREGORIAN")
+	iw.writeLine("METHOD:PUBLISH")
+	iw.writeLine("X-WR-CALNAME:Hebcal")
+	if tz != nil && hasTimedEvents(events) {
+		iw.writeLine("X-WR-TIMEZONE:" + tzid)
+		writeVTimezone(&iw, tz, events)
+	}
+
+	dtstamp := time.Now().UTC().Format(icalDateTimeFormat) + "Z"
+	for _, ev := range events {
+		iw.writeLine("BEGIN:VEVENT")
+		iw.writeLine("DTSTAMP:" + dtstamp)
+		iw.writeLine("UID:" + icalUID(ev, locationName))
+		timedEv, isTimed := ev.(hebcal.TimedEvent)
+		if isTimed && tz != nil {
+			start := timedEv.EventTime.In(tz).Format(icalDateTimeFormat)
+			iw.writeLine("SUMMARY:" + icalEscape(eventTitle(ev, lang)))
+			iw.writeLine("DTSTART;TZID=" + tzid + ":" + start)
+			iw.writeLine("DTEND;TZID=" + tzid + ":" + start)
+			if locationName != "" {
+				iw.writeLine("LOCATION:" + icalEscape(locationName))
+			}
+			if timedEv.LinkedEvent != nil {
+				iw.writeLine("DESCRIPTION:" + icalEscape(timedEv.LinkedEvent.Render(lang)))
+			}
+		} else {
+			hd := ev.GetDate()
+			iw.writeLine("SUMMARY:" + icalEscape(ev.Render(lang)))
+			iw.writeLine("DTSTART;VALUE=DATE:" + icalDate(hd))
+			iw.writeLine("DTEND;VALUE=DATE:" + icalDate(hd.Next()))
+		}
+		iw.writeLine("TRANSP:TRANSPARENT")
+		categories := flagNames(ev.GetFlags())
+		if len(categories) != 0 {
+			iw.writeLine("CATEGORIES:" + strings.Join(categories, ","))
+		}
+		iw.writeLine("END:VEVENT")
+	}
+	iw.writeLine("END:VCALENDAR")
+	return iw.w.Flush()
+}
+
+func icalDate(hd hdate.HDate) string {
+	year, month, day := hd.Greg()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Format(icalDateFormat)
+}
+
+func hasTimedEvents(events []event.CalEvent) bool {
+	for _, ev := range events {
+		if _, ok := ev.(hebcal.TimedEvent); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type tzTransition struct {
+	at         time.Time // instant of the transition
+	fromOffset int       // seconds east of UTC before the transition
+	toOffset   int       // seconds east of UTC after the transition
+	name       string    // abbreviation in effect after the transition
+}
+
+// findTransitions returns every UTC offset change in tz during the
+// given Gregorian years. The Go time package doesn't expose the zone
+// rules directly, so we probe once a day and then bisect each change
+// down to the second.
+func findTransitions(tz *time.Location, firstYear, lastYear int) []tzTransition {
+	transitions := make([]tzTransition, 0, 2*(lastYear-firstYear+1))
+	t := time.Date(firstYear, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(lastYear+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	_, offset := t.In(tz).Zone()
+	for t.Before(end) {
+		next := t.Add(24 * time.Hour)
+		name, nextOffset := next.In(tz).Zone()
+		if nextOffset != offset {
+			lo, hi := t.Unix(), next.Unix()
+			for hi-lo > 1 {
+				mid := (lo + hi) / 2
+				if _, off := time.Unix(mid, 0).In(tz).Zone(); off == offset {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			transitions = append(transitions, tzTransition{
+				at:         time.Unix(hi, 0),
+				fromOffset: offset,
+				toOffset:   nextOffset,
+				name:       name,
+			})
+			offset = nextOffset
+		}
+		t = next
+	}
+	return transitions
+}
+
+func icalOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, (seconds%3600)/60)
+}
+
+// writeVTimezone describes tz with one STANDARD or DAYLIGHT observance
+// per offset change between the first and last event.
+func writeVTimezone(iw *icalWriter, tz *time.Location, events []event.CalEvent) {
+	firstYear, _, _ := events[0].GetDate().Greg()
+	lastYear, _, _ := events[len(events)-1].GetDate().Greg()
+	transitions := findTransitions(tz, firstYear-1, lastYear)
+
+	iw.writeLine("BEGIN:VTIMEZONE")
+	iw.writeLine("TZID:" + tz.String())
+	if len(transitions) == 0 {
+		name, offset := time.Date(firstYear, time.January, 1, 0, 0, 0, 0, tz).Zone()
+		iw.writeLine("BEGIN:STANDARD")
+		iw.writeLine("DTSTART:19700101T000000")
+		iw.writeLine("TZOFFSETFROM:" + icalOffset(offset))
+		iw.writeLine("TZOFFSETTO:" + icalOffset(offset))
+		iw.writeLine("TZNAME:" + name)
+		iw.writeLine("END:STANDARD")
+	}
+	for _, tr := range transitions {
+		// the larger of the two offsets is daylight saving time
+		kind := "STANDARD"
+		if tr.toOffset > tr.fromOffset {
+			kind = "DAYLIGHT"
+		}
+		// DTSTART is expressed in the local time before the change
+		local := tr.at.In(time.FixedZone("", tr.fromOffset))
+		iw.writeLine("BEGIN:" + kind)
+		iw.writeLine("DTSTART:" + local.Format(icalDateTimeFormat))
+		iw.writeLine("TZOFFSETFROM:" + icalOffset(tr.fromOffset))
+		iw.writeLine("TZOFFSETTO:" + icalOffset(tr.toOffset))
+		iw.writeLine("TZNAME:" + tr.name)
+		iw.writeLine("END:" + kind)
+	}
+	iw.writeLine("END:VTIMEZONE")
+}
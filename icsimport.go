@@ -0,0 +1,489 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hebcal/greg"
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+)
+
+// icsProperty is one unfolded content line of an iCalendar file, e.g.
+// DTSTART;TZID=America/New_York:20250101T190000
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+	Line   int // line number where the property begins
+}
+
+// icsWeekday is one BYDAY entry of an RRULE, e.g. 2TU or -1SU.
+// N is 0 for every such weekday.
+type icsWeekday struct {
+	N   int
+	Day time.Weekday
+}
+
+// icsRule is the subset of RFC 5545 RRULE supported by --ics-infile
+type icsRule struct {
+	Freq       string // DAILY, WEEKLY, MONTHLY or YEARLY
+	Interval   int
+	Count      int       // 0 if unlimited
+	Until      time.Time // zero if unlimited
+	UntilDate  bool      // UNTIL is a DATE, compared without a time zone
+	ByDay      []icsWeekday
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// icsEvent is a VEVENT read from an --ics-infile
+type icsEvent struct {
+	Summary string
+	Start   time.Time // in the time zone of DTSTART
+	AllDay  bool
+	Days    int // length of an all-day event
+	Rule    *icsRule
+	ExDates map[string]bool // excluded dates, as YYYYMMDD
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// readICSProperties unfolds the content lines of an iCalendar file.
+func readICSProperties(r io.Reader) ([]icsProperty, error) {
+	scanner := bufio.NewScanner(r)
+	props := make([]icsProperty, 0, 100)
+	var line string
+	lineNumber, startLine := 0, 0
+	flush := func() {
+		if line != "" {
+			if prop, ok := parseICSProperty(line); ok {
+				prop.Line = startLine
+				props = append(props, prop)
+			}
+		}
+	}
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		lineNumber++
+		if strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t") {
+			line += text[1:]
+			continue
+		}
+		flush()
+		line, startLine = text, lineNumber
+	}
+	flush()
+	return props, scanner.Err()
+}
+
+// parseICSProperty splits NAME;PARAM=VALUE;...:VALUE, allowing quoted
+// parameter values that contain ':' or ';'.
+func parseICSProperty(line string) (icsProperty, bool) {
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon == -1 {
+		return icsProperty{}, false
+	}
+	prop := icsProperty{Params: make(map[string]string), Value: line[colon+1:]}
+	parts := strings.Split(line[:colon], ";")
+	prop.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		if i := strings.Index(param, "="); i != -1 {
+			prop.Params[strings.ToUpper(param[:i])] = strings.Trim(param[i+1:], `"`)
+		}
+	}
+	return prop, true
+}
+
+func icsUnescape(str string) string {
+	var sb strings.Builder
+	for i := 0; i < len(str); i++ {
+		if str[i] == '\\' && i+1 < len(str) {
+			i++
+			switch str[i] {
+			case 'n', 'N':
+				sb.WriteByte('\n')
+			default:
+				sb.WriteByte(str[i])
+			}
+			continue
+		}
+		sb.WriteByte(str[i])
+	}
+	return sb.String()
+}
+
+// parseICSTime parses a DATE or DATE-TIME value. Floating times, with
+// neither a TZID nor a trailing Z, are taken to be in defaultLoc.
+func parseICSTime(prop icsProperty, defaultLoc *time.Location) (time.Time, bool, error) {
+	value := prop.Value
+	if prop.Params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, time.UTC)
+		return t, true, err
+	}
+	loc := defaultLoc
+	if strings.HasSuffix(value, "Z") {
+		loc = time.UTC
+		value = strings.TrimSuffix(value, "Z")
+	} else if tzid := prop.Params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+func parseICSRule(value string, defaultLoc *time.Location) (*icsRule, error) {
+	rule := &icsRule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		i := strings.Index(part, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("invalid RRULE part %s", part)
+		}
+		name, val := strings.ToUpper(part[:i]), part[i+1:]
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(val)
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(val)
+			if err == nil && rule.Interval < 1 {
+				err = fmt.Errorf("invalid INTERVAL %s", val)
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(val)
+		case "UNTIL":
+			rule.Until, rule.UntilDate, err = parseICSTime(icsProperty{Value: val}, defaultLoc)
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				n := len(day) - 2
+				if n < 0 {
+					return nil, fmt.Errorf("invalid BYDAY %s", val)
+				}
+				dow, ok := icsWeekdays[strings.ToUpper(day[n:])]
+				if !ok {
+					return nil, fmt.Errorf("invalid BYDAY %s", val)
+				}
+				wd := icsWeekday{Day: dow}
+				if n > 0 {
+					if wd.N, err = strconv.Atoi(day[:n]); err != nil {
+						return nil, fmt.Errorf("invalid BYDAY %s", val)
+					}
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, str := range strings.Split(val, ",") {
+				day, err := strconv.Atoi(str)
+				if err != nil || day == 0 || day < -31 || day > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY %s", val)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, day)
+			}
+		case "BYMONTH":
+			for _, str := range strings.Split(val, ",") {
+				month, err := strconv.Atoi(str)
+				if err != nil || month < 1 || month > 12 {
+					return nil, fmt.Errorf("invalid BYMONTH %s", val)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(month))
+			}
+		case "WKST":
+			// weeks always start on Monday
+		default:
+			return nil, fmt.Errorf("unsupported RRULE part %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid RRULE %s: %v", name, err)
+		}
+	}
+	switch rule.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, fmt.Errorf("unsupported RRULE FREQ %s", rule.Freq)
+	}
+	return rule, nil
+}
+
+// parseICSEvents reads the VEVENTs of an iCalendar file. A VEVENT
+// that cannot be used is reported with its line number and skipped.
+func parseICSEvents(r io.Reader, filename string, defaultLoc *time.Location) ([]icsEvent, int, error) {
+	props, err := readICSProperties(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	events := make([]icsEvent, 0, 10)
+	numErrors := 0
+	var ev *icsEvent
+	var end time.Time
+	var evErr error
+	var evLine int
+	for _, prop := range props {
+		switch prop.Name {
+		case "BEGIN":
+			if strings.EqualFold(prop.Value, "VEVENT") {
+				ev = &icsEvent{Days: 1, ExDates: make(map[string]bool)}
+				end = time.Time{}
+				evErr = nil
+				evLine = prop.Line
+			}
+			continue
+		case "END":
+			if ev == nil || !strings.EqualFold(prop.Value, "VEVENT") {
+				continue
+			}
+			if evErr == nil && ev.Start.IsZero() {
+				evErr = fmt.Errorf("missing DTSTART")
+			}
+			if evErr != nil {
+				fmt.Fprintf(os.Stderr, "error, %v: %s:%d\n", evErr, filename, evLine)
+				numErrors++
+			} else {
+				if ev.AllDay && !end.IsZero() {
+					if days := int(end.Sub(ev.Start).Hours()/24 + 0.5); days > 1 {
+						ev.Days = days
+					}
+				}
+				events = append(events, *ev)
+			}
+			ev = nil
+			continue
+		}
+		if ev == nil || evErr != nil {
+			continue
+		}
+		switch prop.Name {
+		case "SUMMARY":
+			ev.Summary = icsUnescape(prop.Value)
+		case "DTSTART":
+			ev.Start, ev.AllDay, err = parseICSTime(prop, defaultLoc)
+		case "DTEND":
+			end, _, err = parseICSTime(prop, defaultLoc)
+		case "RRULE":
+			ev.Rule, err = parseICSRule(prop.Value, defaultLoc)
+		case "EXDATE":
+			for _, value := range strings.Split(prop.Value, ",") {
+				prop.Value = value
+				t, _, err := parseICSTime(prop, defaultLoc)
+				if err != nil {
+					evErr = fmt.Errorf("invalid EXDATE %s", value)
+					break
+				}
+				ev.ExDates[t.In(ev.Start.Location()).Format("20060102")] = true
+			}
+		}
+		if err != nil {
+			evErr = fmt.Errorf("invalid %s %s", prop.Name, prop.Value)
+			err = nil
+		}
+	}
+	return events, numErrors, nil
+}
+
+// readICSFile reads an --ics-infile. Problems are fatal if --strict
+// is in effect.
+func readICSFile(filename string, defaultLoc *time.Location) []icsEvent {
+	f, err := os.Open(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not open ics input file %s.\n", filename)
+		os.Exit(1)
+	}
+	defer f.Close()
+	events, numErrors, err := parseICSEvents(f, filename, defaultLoc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error, %v: %s\n", err, filename)
+		os.Exit(1)
+	}
+	if numErrors != 0 && strict_sw {
+		os.Exit(1)
+	}
+	return events
+}
+
+// mondayOf returns the R.D. date of the Monday on or before abs.
+func mondayOf(abs int64) int64 {
+	return hdate.DayOnOrBefore(time.Monday, abs)
+}
+
+// matchesDay reports whether a day of the month satisfies BYMONTHDAY
+// and BYDAY, or falls on the same day of the month as DTSTART.
+func (rule *icsRule) matchesDay(year int, month time.Month, day int, dow time.Weekday, startDay int) bool {
+	if len(rule.ByMonthDay) == 0 && len(rule.ByDay) == 0 {
+		return day == startDay
+	}
+	daysInMonth := greg.DaysIn(month, year)
+	if len(rule.ByMonthDay) != 0 {
+		found := false
+		for _, md := range rule.ByMonthDay {
+			if md == day || (md < 0 && daysInMonth+md+1 == day) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(rule.ByDay) != 0 {
+		found := false
+		for _, wd := range rule.ByDay {
+			if wd.Day != dow {
+				continue
+			}
+			if wd.N == 0 || (wd.N > 0 && (day-1)/7+1 == wd.N) ||
+				(wd.N < 0 && (daysInMonth-day)/7+1 == -wd.N) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether the day abs is an occurrence of an event
+// beginning on the day startAbs.
+func (rule *icsRule) matches(abs, startAbs int64) bool {
+	year, month, day := greg.FromRD(abs)
+	startYear, startMonth, startDay := greg.FromRD(startAbs)
+	dow := time.Weekday(abs % 7)
+	interval := int64(rule.Interval)
+	switch rule.Freq {
+	case "DAILY":
+		return (abs-startAbs)%interval == 0
+	case "WEEKLY":
+		if (mondayOf(abs)-mondayOf(startAbs))/7%interval != 0 {
+			return false
+		}
+		if len(rule.ByDay) == 0 {
+			return dow == time.Weekday(startAbs%7)
+		}
+		for _, wd := range rule.ByDay {
+			if wd.Day == dow {
+				return true
+			}
+		}
+		return false
+	case "MONTHLY":
+		months := (year-startYear)*12 + int(month-startMonth)
+		if int64(months)%interval != 0 {
+			return false
+		}
+		return rule.matchesDay(year, month, day, dow, startDay)
+	case "YEARLY":
+		if int64(year-startYear)%interval != 0 {
+			return false
+		}
+		byMonth := rule.ByMonth
+		if len(byMonth) == 0 {
+			byMonth = []time.Month{startMonth}
+		}
+		for _, m := range byMonth {
+			if m == month {
+				return rule.matchesDay(year, month, day, dow, startDay)
+			}
+		}
+	}
+	return false
+}
+
+// occurrences returns the start of each occurrence of ev up to the
+// day lastAbs, in the time zone of DTSTART.
+func (ev icsEvent) occurrences(lastAbs int64) []time.Time {
+	year, month, day := ev.Start.Date()
+	startAbs := greg.ToRD(year, month, day)
+	if ev.Rule == nil {
+		return []time.Time{ev.Start}
+	}
+	if !ev.Rule.Until.IsZero() {
+		until := ev.Rule.Until
+		if !ev.Rule.UntilDate {
+			until = until.In(ev.Start.Location())
+		}
+		y, m, d := until.Date()
+		if untilAbs := greg.ToRD(y, m, d); untilAbs < lastAbs {
+			lastAbs = untilAbs
+		}
+	}
+	times := make([]time.Time, 0, 10)
+	count := 0
+	for abs := startAbs; abs <= lastAbs; abs++ {
+		if abs != startAbs && !ev.Rule.matches(abs, startAbs) {
+			continue
+		}
+		count++
+		if ev.Rule.Count != 0 && count > ev.Rule.Count {
+			break
+		}
+		y, m, d := greg.FromRD(abs)
+		t := time.Date(y, m, d, ev.Start.Hour(), ev.Start.Minute(), ev.Start.Second(), 0, ev.Start.Location())
+		if !ev.ExDates[t.Format("20060102")] {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
+// icsEventsInRange expands events from an --ics-infile into calendar
+// events from start to end. Timed events are shown in the time zone of
+// --city or --timezone, or else the local time zone.
+func icsEventsInRange(events []icsEvent, start, end hdate.HDate, calOptions *hebcal.CalOptions) []event.CalEvent {
+	result := make([]event.CalEvent, 0, len(events))
+	startAbs, endAbs := start.Abs(), end.Abs()
+	displayLoc := icsDisplayLocation(calOptions)
+	for _, ev := range events {
+		// a day of slack for time zone differences
+		for _, t := range ev.occurrences(endAbs + 1) {
+			if ev.AllDay {
+				year, month, day := t.Date()
+				first := greg.ToRD(year, month, day)
+				for abs := first; abs < first+int64(ev.Days); abs++ {
+					if abs >= startAbs && abs <= endAbs {
+						result = append(result, event.HolidayEvent{
+							Date:  hdate.FromRD(abs),
+							Desc:  ev.Summary,
+							Flags: event.USER_EVENT,
+						})
+					}
+				}
+				continue
+			}
+			t = t.In(displayLoc)
+			year, month, day := t.Date()
+			hd := hdate.FromGregorian(year, month, day)
+			if abs := hd.Abs(); abs >= startAbs && abs <= endAbs {
+				result = append(result, hebcal.NewTimedEvent(hd, ev.Summary,
+					event.USER_EVENT, t, 0, nil, calOptions))
+			}
+		}
+	}
+	return result
+}
+
+func icsDisplayLocation(calOptions *hebcal.CalOptions) *time.Location {
+	if calOptions.Location != nil {
+		if loc, err := time.LoadLocation(calOptions.Location.TimeZoneId); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hebcal/greg"
+)
+
+func TestICSOccurrences(t *testing.T) {
+	tests := []struct {
+		name  string
+		props string
+		want  string
+	}{
+		{"no rule",
+			"DTSTART;VALUE=DATE:20250101",
+			"2025-01-01"},
+		{"daily count",
+			"DTSTART;VALUE=DATE:20250101\nRRULE:FREQ=DAILY;COUNT=3",
+			"2025-01-01 2025-01-02 2025-01-03"},
+		{"daily interval until",
+			"DTSTART;VALUE=DATE:20250101\nRRULE:FREQ=DAILY;INTERVAL=10;UNTIL=20250131",
+			"2025-01-01 2025-01-11 2025-01-21 2025-01-31"},
+		{"date-only until west of UTC",
+			"DTSTART;TZID=America/Los_Angeles:20250101T190000\nRRULE:FREQ=DAILY;UNTIL=20250103",
+			"2025-01-01 2025-01-02 2025-01-03"},
+		{"weekly byday",
+			"DTSTART;VALUE=DATE:20250106\nRRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4",
+			"2025-01-06 2025-01-08 2025-01-13 2025-01-15"},
+		{"biweekly",
+			"DTSTART;VALUE=DATE:20250106\nRRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=3",
+			"2025-01-06 2025-01-20 2025-02-03"},
+		{"monthly nth weekday",
+			"DTSTART;VALUE=DATE:20250114\nRRULE:FREQ=MONTHLY;BYDAY=2TU;COUNT=3",
+			"2025-01-14 2025-02-11 2025-03-11"},
+		{"monthly last day",
+			"DTSTART;VALUE=DATE:20250131\nRRULE:FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3",
+			"2025-01-31 2025-02-28 2025-03-31"},
+		{"monthly skips short months",
+			"DTSTART;VALUE=DATE:20250131\nRRULE:FREQ=MONTHLY;COUNT=3",
+			"2025-01-31 2025-03-31 2025-05-31"},
+		{"yearly last sunday",
+			"DTSTART;VALUE=DATE:20251130\nRRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=-1SU;COUNT=2",
+			"2025-11-30 2026-11-29"},
+		{"exdate",
+			"DTSTART;VALUE=DATE:20250101\nRRULE:FREQ=DAILY;COUNT=4\nEXDATE;VALUE=DATE:20250102,20250104",
+			"2025-01-01 2025-01-03"},
+		{"exdate in another time zone",
+			"DTSTART;TZID=America/New_York:20250101T200000\nRRULE:FREQ=DAILY;COUNT=3\nEXDATE:20250103T010000Z",
+			"2025-01-01 2025-01-03"},
+	}
+	lastAbs := greg.ToRD(2027, time.January, 1)
+	for _, test := range tests {
+		ics := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Test\n" + test.props + "\nEND:VEVENT\nEND:VCALENDAR\n"
+		events, numErrors, err := parseICSEvents(strings.NewReader(ics), "test.ics", time.UTC)
+		if err != nil || numErrors != 0 || len(events) != 1 {
+			t.Errorf("%s: parseICSEvents = %d events, %d errors, %v", test.name, len(events), numErrors, err)
+			continue
+		}
+		dates := make([]string, 0, 4)
+		for _, occ := range events[0].occurrences(lastAbs) {
+			dates = append(dates, occ.Format("2006-01-02"))
+		}
+		if got := strings.Join(dates, " "); got != test.want {
+			t.Errorf("%s: occurrences = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+)
+
+// jsonEvent is the representation of a calendar event used by
+// --format=json and --format=ndjson
+type jsonEvent struct {
+	Date        string      `json:"date"`  // Gregorian date, YYYY-MM-DD
+	HDate       hdate.HDate `json:"hdate"` // Hebrew date
+	Title       string      `json:"title"` // rendered in --lang
+	Basename    string      `json:"basename"`
+	Emoji       string      `json:"emoji,omitempty"`
+	Flags       []string    `json:"flags"`
+	Time        string      `json:"time,omitempty"` // RFC 3339, only for timed events
+	LinkedEvent *jsonEvent  `json:"linkedEvent,omitempty"`
+}
+
+func newJSONEvent(ev event.CalEvent) *jsonEvent {
+	hd := ev.GetDate()
+	year, month, day := hd.Greg()
+	obj := &jsonEvent{
+		Date:     time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Format("2006-01-02"),
+		HDate:    hd,
+		Title:    eventTitle(ev, lang),
+		Basename: ev.Basename(),
+		Emoji:    ev.GetEmoji(),
+		Flags:    flagNames(ev.GetFlags()),
+	}
+	if timedEv, ok := ev.(hebcal.TimedEvent); ok {
+		obj.Time = timedEv.EventTime.Format(time.RFC3339)
+		if timedEv.LinkedEvent != nil {
+			obj.LinkedEvent = newJSONEvent(timedEv.LinkedEvent)
+		}
+	}
+	return obj
+}
+
+// writeJSON writes events as a single JSON array.
+func writeJSON(out io.Writer, events []event.CalEvent) error {
+	objs := make([]*jsonEvent, 0, len(events))
+	for _, ev := range events {
+		objs = append(objs, newJSONEvent(ev))
+	}
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(objs)
+}
+
+// writeNDJSON writes events as newline-delimited JSON, one object per line.
+func writeNDJSON(out io.Writer, events []event.CalEvent) error {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	for _, ev := range events {
+		if err := enc.Encode(newJSONEvent(ev)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"time"
+
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+	"github.com/hebcal/hebcal-go/molad"
+	"github.com/hebcal/hebcal-go/zmanim"
+)
+
+// jerusalemMeanTime is the local mean time of Jerusalem (longitude
+// 35.2354° E, 2:20:56 ahead of UTC), in which the molad is reckoned.
+var jerusalemMeanTime = time.FixedZone("JMT", 2*3600+20*60+56)
+
+// halfLunation is half the mean lunation of 29 days, 12 hours and 793
+// chalakim, each 3⅓ seconds.
+const halfLunation = 765433 * 10 * time.Second / 3 / 2
+
+// moladTime returns the moment of the molad of the month that hd is in.
+func moladTime(hd hdate.HDate) time.Time {
+	m := molad.New(hd.Year(), hd.Month())
+	year, month, day := m.Date.Greg()
+	return time.Date(year, month, day, m.Hours, m.Minutes, 0, 0, jerusalemMeanTime).
+		Add(time.Duration(m.Chalakim) * 10 * time.Second / 3)
+}
+
+// kiddushLevanaEvents returns the earliest and latest times to say
+// Kiddush Levana for each month, and the first Motzei Shabbat in
+// between, for those who wait for it. The earliest time is
+// earliestDays after the molad; the latest is 15 days after it or,
+// if halfMonth is true, half a lunation.
+func kiddushLevanaEvents(start, end hdate.HDate, earliestDays int, halfMonth bool, obs observer, calOptions *hebcal.CalOptions) []event.CalEvent {
+	loc, _ := time.LoadLocation(obs.TimeZoneId)
+	events := make([]event.CalEvent, 0, 40)
+	add := func(t time.Time, desc string) {
+		hd := hdate.FromTime(t)
+		if hd.Abs() >= start.Abs() && hd.Abs() <= end.Abs() {
+			events = append(events, hebcal.NewTimedEvent(hd, desc, KIDDUSH_LEVANA, t, 0, nil, calOptions))
+		}
+	}
+	// the window of the previous month may still be open at start
+	first := hdate.New(start.Year(), start.Month(), 1).Prev()
+	for hd := hdate.New(first.Year(), first.Month(), 1); hd.Abs() <= end.Abs(); hd = hdate.FromRD(hd.Abs() + int64(hd.DaysInMonth())) {
+		moladAt := moladTime(hd)
+		earliest := moladAt.Add(time.Duration(earliestDays) * 24 * time.Hour).In(loc)
+		latest := moladAt.Add(15 * 24 * time.Hour).In(loc)
+		if halfMonth {
+			latest = moladAt.Add(halfLunation).In(loc)
+		}
+		add(earliest, "Kiddush Levana, earliest")
+		lastDay := hdate.FromTime(latest)
+		motzeiShabbat := hdate.FromTime(earliest).OnOrAfter(time.Saturday)
+		for ; motzeiShabbat.Abs() <= lastDay.Abs(); motzeiShabbat = hdate.FromRD(motzeiShabbat.Abs() + 7) {
+			z := newDayZmanim(obs, motzeiShabbat)
+			tzeit := z.timeAtAngle(zmanim.Tzeit3SmallStars, false)
+			if tzeit.IsZero() || tzeit.After(latest) {
+				break
+			}
+			if tzeit.After(earliest) {
+				add(tzeit, "Kiddush Levana, Motzei Shabbat")
+				break
+			}
+		}
+		add(latest, "Kiddush Levana, latest")
+	}
+	return events
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"regexp"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"os"
@@ -16,6 +18,7 @@ import (
 	"github.com/hebcal/hebcal-go/locales"
 	"github.com/hebcal/hebcal-go/yerushalmi"
 	"github.com/hebcal/hebcal-go/zmanim"
+	"github.com/hebcal/hebcal/haftarah"
 	getopt "github.com/pborman/getopt/v2"
 )
 
@@ -50,8 +53,22 @@ var noGreg_sw = false
 var yearDigits_sw = false
 var isTodayChag_sw = false
 var verbose_sw = false
-
-func handleArgs() hebcal.CalOptions {
+var outputFormat = "text"
+var grid_sw = false
+var eventTemplate *template.Template
+var anniversaries []userAnniversary
+var userRules []userRule
+var strict_sw = false
+var icsEvents []icsEvent
+var zmanimList []zman
+var shabbatTable_sw = false
+var minchaMins = 10
+var kiddushLevana_sw = false
+var kiddushLevanaEarliest = 3
+var kiddushLevanaLatest = "15"
+var haftarahMinhag = "ashkenazi"
+
+func handleArgs() (hebcal.CalOptions, observer) {
 	calOptions := hebcal.CalOptions{}
 	opt := getopt.New()
 	opt.SetProgram("hebcal")
@@ -63,6 +80,8 @@ func handleArgs() hebcal.CalOptions {
 		iso8601dates_sw = opt.BoolLong("iso-8601", 'g', "Output ISO 8601 dates -- YYYY-MM-DD")
 		version_sw      = opt.BoolLong("version", 0, "Show version number")
 		cityNameArg     = opt.StringLong("city", 'C', "", "City for candle-lighting", "CITY")
+		zipArg          = opt.StringLong("zip", 0, "", "US ZIP code for candle-lighting (see 'hebcal geo-import')", "ZIP")
+		geonameidArg    = opt.StringLong("geonameid", 0, "", "GeoNames id of a city for candle-lighting (see 'hebcal geo-import')", "ID")
 		utf8_hebrew_sw  = opt.BoolLong("", '8', "Use UTF-8 Hebrew (alias for --lang=he)")
 		schottenstein   = opt.BoolLong("schottenstein", 0, "Use Schottenstein edition of Yerushalmi Yomi")
 	)
@@ -78,11 +97,17 @@ func handleArgs() hebcal.CalOptions {
 	opt.FlagLong(&longitudeStr,
 		"longitude", 'L', "Set the longitude for solar calculations to XX degrees and YY minutes. Negative values are EAST. The -l and -L switches must both be used, or not at all.", "XX,YY")
 	opt.FlagLong(&tzid, "timezone", 'z', "Use specified timezone, overriding the -C (localize to city) switch", "TIMEZONE")
+	var elevation float64
+	opt.FlagLong(&elevation, "elevation", 0, "Adjust sunrise, sunset and the times based on them for an elevation of METERS above sea level, overriding the elevation of a --zip or --geonameid location", "METERS")
 
 	opt.FlagLong(&today_sw, "today", 't', "Only output for today's date")
 	opt.FlagLong(&noGreg_sw, "today-brief", 'T', "Print today's pertinent information")
+	opt.FlagLong(&afterSunset_sw, "after-sunset", 0,
+		"With 'hebcal convert', Gregorian dates are after sunset (use the next Hebrew day)")
 	opt.FlagLong(&isTodayChag_sw, "exit-if-chag", 'X',
 		"Exit silently with non-zero status if today is Shabbat or Chag; exit with 0 status if today is chol")
+	opt.FlagLong(&strict_sw, "strict", 0,
+		"Exit with non-zero status if an input file has any problems")
 	opt.FlagLong(&verbose_sw, "verbose", 0,
 		"Verbose mode, currently used only for --exit-if-chag")
 	var chagOnly_sw = false
@@ -92,15 +117,39 @@ func handleArgs() hebcal.CalOptions {
 	opt.FlagLong(&yearDigits_sw, "year-abbrev", 'y', "Print only last two digits of year")
 	opt.FlagLong(&tabs_sw, "tabs", 'r', "Tab delineated format")
 	opt.FlagLong(&weekday_sw, "weekday", 'w', "Add day of the week")
+	opt.FlagLong(&grid_sw, "grid", 0, "Display each month as a grid, similar to cal(1)")
+	opt.FlagLong(&shabbatTable_sw, "shabbat-table", 0,
+		"Print a table of Shabbat and Yom Tov times, one row per week (text, csv or html)")
+	opt.FlagLong(&minchaMins, "mincha-mins", 0,
+		"With --shabbat-table, Mincha is this many minutes before candle lighting", "MINUTES")
+	var templateText, templateFileName string
+	opt.FlagLong(&templateText, "template", 0, "Format each event with Go text/template TEMPLATE", "TEMPLATE")
+	opt.FlagLong(&templateFileName, "template-file", 0, "Read the --template from FILENAME", "FILENAME")
 	opt.FlagLong(&calOptions.Hour24,
 		"24hour", 'E', "Output 24-hour times (e.g. 18:37 instead of 6:37)")
 	opt.FlagLong(&calOptions.SunriseSunset,
 		"sunrise-and-sunset", 'O', "Output sunrise and sunset times every day")
 	opt.FlagLong(&calOptions.DailyZmanim, "zmanim", 'Z', "Output zemanim every day")
+	var zmanimSetName string
+	opt.FlagLong(&zmanimSetName, "zmanim-set", 0,
+		"Output the zemanim of opinion SET every day ("+strings.Join(zmanimSetNames(), ", ")+")", "SET")
+	zmanSpecs := opt.ListLong("zman", 0, `Output a custom zeman NAME every day, where SPEC is
+sunrise or sunset, optionally followed by +MINUTES or -MINUTES
+(with a z suffix for proportional minutes), dawn:DEGREES or
+dusk:DEGREES below the horizon, or hours:N[:DAY] for N sha'ot
+zmaniyot into a DAY of gra, mga, NNmin, DEGdeg or ateret-torah`, "NAME=SPEC")
 	opt.FlagLong(&calOptions.Molad, "molad", 'M', "Print the molad on Shabbat Mevorchim")
+	opt.FlagLong(&kiddushLevana_sw, "kiddush-levana", 0,
+		"Print the earliest and latest times for Kiddush Levana each month, and the first Motzei Shabbat in between")
+	opt.FlagLong(&kiddushLevanaEarliest, "kiddush-levana-earliest", 0,
+		"Kiddush Levana may be said from 3 or 7 DAYS after the molad (default 3)", "DAYS")
+	opt.FlagLong(&kiddushLevanaLatest, "kiddush-levana-latest", 0,
+		"Kiddush Levana may be said until 15 days after the molad, or half the month (15 or half; default 15)", "LATEST")
 	opt.FlagLong(&calOptions.WeeklyAbbreviated,
 		"abbrev", 'W', "Weekly view. Omer, dafyomi, and non-date-specific zemanim are shown once a week, on the day which corresponds to the first day in the range.")
 
+	opt.FlagLong(&outputFormat, "format", 0, "Output format ("+strings.Join(outputFormats, ", ")+")", "FORMAT")
+
 	langList := strings.Join(locales.AllLocales, ", ")
 	opt.FlagLong(&lang, "lang", 0, "Use LANG titles ("+langList+")", "LANG")
 
@@ -142,6 +191,8 @@ func handleArgs() hebcal.CalOptions {
 		"sedrot", 's', "Add the weekly sedra to the output on Saturdays")
 	opt.FlagLong(&calOptions.DailySedra,
 		"daily-sedra", 'S', "Add the weekly sedra to the output every day")
+	opt.FlagLong(&haftarahMinhag, "haftarah-minhag", 0,
+		"With -s or -S, follow MINHAG for the haftarah ("+strings.Join(haftarah.Minhagim, ", ")+")", "MINHAG")
 
 	calOptions.CandleLightingMins = 18
 	opt.FlagLong(&calOptions.CandleLightingMins,
@@ -160,35 +211,88 @@ func handleArgs() hebcal.CalOptions {
 Each line specifies one holiday, with the format:
     MMMM DD Description
 where MMMM is a string identifying the Hebrew month,
-and DD is a number from 1 to 30.
+and DD is a number from 1 to 30. Instead of MMMM DD,
+the date may be given as
+    MMMM last                  last day of a Hebrew month
+    MM/DD                      Gregorian date
+    ORDINAL WEEKDAY of MONTH   e.g. first Shabbat of Cheshvan,
+                               last Sunday of November
+    HOLIDAY +N or HOLIDAY -N   e.g. Pesach I -30
+optionally followed by "for N days" for a multi-day event.
 Description is a newline-terminated string describing
 the event. Events are printed regardless of the
 -h (suppress holidays) switch.`, "FILENAME")
 	yahrzeitFileName := opt.StringLong("yahrtzeit", 'Y', "", `Read yahrtzeit dates from FILENAME.
 Each line specifies one death-date, with the format:
-    MM DD YYYY Description
-where MM, DD and YYYY are the Gregorian date of death.
+    MM DD YYYY [after-sunset] Description
+where MM, DD and YYYY are the Gregorian date of death,
+or
+    MMMM DD YYYY Description
+where MMMM DD YYYY is the Hebrew date of death.
+Use after-sunset if death occurred after sunset, which
+moves a Gregorian date of death to the next Hebrew day.
 Description is a newline-terminated string to be printed
 on the yahrtzeit. Events are printed regardless of the
+-h (suppress holidays) switch.`, "FILENAME")
+	birthdayFileName := opt.StringLong("birthdays", 'B', "", `Read Hebrew birthdays and anniversaries from FILENAME.
+Each line specifies one event, with the format:
+    TYPE MM DD YYYY [after-sunset] Name[; Note]
+or
+    TYPE MMMM DD YYYY Name[; Note]
+where TYPE is birthday or anniversary, followed by the
+Gregorian or Hebrew date as for -Y. Each occurrence is
+annotated with the number of years since the original
+Hebrew date. Events are printed regardless of the
 -h (suppress holidays) switch.`, "FILENAME")
 
-	if err := opt.Getopt(os.Args, nil); err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+	icsFileName := opt.StringLong("ics-infile", 0, "", `Read extra events from the iCalendar (.ics) FILENAME.
+Recurring events (RRULE with FREQ=DAILY, WEEKLY, MONTHLY
+or YEARLY) are expanded. Events are printed regardless of
+the -h (suppress holidays) switch.`, "FILENAME")
+
+	opt.StringLong("profile", 0, "", "Use the options in [profile.NAME] of the config file", "NAME")
+
+	longNames := make(map[string]bool)
+	opt.VisitAll(func(o getopt.Option) {
+		longNames[o.LongName()] = true
+	})
+	config, err := readConfigFile(configFileName(), func(name string) bool {
+		return name != "" && longNames[name]
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error, %v\n", err)
+		os.Exit(1)
+	}
+	envArgs, err := splitShellWords(os.Getenv("HEBCAL_OPTS"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "HEBCAL_OPTS: %v\n", err)
 		os.Exit(1)
 	}
+	var profileArgs []string
+	allArgs := append(append(append([]string{}, config.Defaults...), envArgs...), os.Args[1:]...)
+	if name := findProfileArg(allArgs); name != "" {
+		args, ok := config.Profiles[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown profile: %s\n", name)
+			os.Exit(1)
+		}
+		profileArgs = args
+	}
 
-	envOpts := os.Getenv("HEBCAL_OPTS")
-	if envOpts != "" {
-		spaceOrTab := func(c rune) bool {
-			return c == ' ' || c == '\t'
+	// config file < profile < HEBCAL_OPTS < command line
+	for _, args := range [][]string{config.Defaults, profileArgs, envArgs} {
+		if len(args) == 0 {
+			continue
 		}
-		args := strings.FieldsFunc(envOpts, spaceOrTab)
-		args = append([]string{"hebcal"}, args...)
-		if err := opt.Getopt(args, nil); err != nil {
+		if err := opt.Getopt(append([]string{"hebcal"}, args...), nil); err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(1)
 		}
 	}
+	if err := opt.Getopt(os.Args, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 
 	if *help {
 		displayHelp(opt)
@@ -219,12 +323,55 @@ on the yahrtzeit. Events are printed regardless of the
 		lang = "he"
 	}
 	checkLang()
+	checkFormat()
+	if grid_sw && outputFormat != "text" {
+		fmt.Fprintf(os.Stderr, "Cannot specify both --grid and --format=%s\n", outputFormat)
+		os.Exit(1)
+	}
+
+	if shabbatTable_sw {
+		if grid_sw || (outputFormat != "text" && outputFormat != "csv" && outputFormat != "html") {
+			fmt.Fprintf(os.Stderr, "--shabbat-table works only with --format=text, csv or html\n")
+			os.Exit(1)
+		}
+		calOptions.CandleLighting = true
+		calOptions.NoHolidays = false
+	}
+
+	if templateFileName != "" {
+		if templateText != "" {
+			fmt.Fprintf(os.Stderr, "Cannot specify both --template and --template-file\n")
+			os.Exit(1)
+		}
+		b, err := ioutil.ReadFile(templateFileName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not read template file %s.\n", templateFileName)
+			os.Exit(1)
+		}
+		templateText = string(b)
+	}
+	if templateText != "" {
+		if grid_sw || outputFormat != "text" {
+			fmt.Fprintf(os.Stderr, "--template works only with plain text output\n")
+			os.Exit(1)
+		}
+		tmpl, err := newEventTemplate(templateText, calOptions.Hour24)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		eventTemplate = tmpl
+	}
 
 	validCity := false
+	var obs observer
 	if cityNameArg != nil && *cityNameArg != "" {
 		city := zmanim.LookupCity(*cityNameArg)
 		if city == nil {
 			fmt.Fprintf(os.Stderr, "unknown city: %s. Use a nearby city or geographic coordinates.\n", *cityNameArg)
+			if names := suggestCities(*cityNameArg, 5); len(names) != 0 {
+				fmt.Fprintf(os.Stderr, "Did you mean: %s?\n", strings.Join(names, ", "))
+			}
 			os.Exit(1)
 		}
 		calOptions.Location = city
@@ -241,6 +388,29 @@ on the yahrtzeit. Events are printed regardless of the
 		}
 	}
 
+	if *zipArg != "" || *geonameidArg != "" {
+		var place observer
+		var err error
+		if *zipArg != "" {
+			place, err = lookupZip(*zipArg)
+		} else {
+			id, err1 := strconv.ParseInt(*geonameidArg, 10, 64)
+			if err1 != nil {
+				fmt.Fprintf(os.Stderr, "invalid geonameid: %s\n", *geonameidArg)
+				os.Exit(1)
+			}
+			place, err = lookupGeonameid(id)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		calOptions.Location = place.Location
+		obs.Elevation = place.Elevation
+		calOptions.CandleLighting = true
+		validCity = true
+	}
+
 	latitude := 0.0
 	hasLat := false
 	if latitudeStr != "" {
@@ -315,13 +485,48 @@ on the yahrtzeit. Events are printed regardless of the
 		}
 		userLocation := zmanim.NewLocation("User Defined City", "", latitude, longitude, tzid)
 		calOptions.Location = &userLocation
+		obs.Elevation = 0
 		calOptions.CandleLighting = true
 		validCity = true
 	}
 
-	if !validCity && (calOptions.CandleLighting || calOptions.SunriseSunset || calOptions.DailyZmanim) {
+	if opt.IsSet("elevation") {
+		if elevation < 0 {
+			fmt.Fprintf(os.Stderr, "Error, elevation must not be negative: %g\n", elevation)
+			os.Exit(1)
+		}
+		obs.Elevation = elevation
+	}
+
+	haftarahMinhag = strings.ToLower(haftarahMinhag)
+	if !haftarah.ValidMinhag(haftarahMinhag) {
+		fmt.Fprintf(os.Stderr, "Error, unknown --haftarah-minhag %s (expected %s)\n",
+			haftarahMinhag, strings.Join(haftarah.Minhagim, ", "))
+		os.Exit(1)
+	}
+	if kiddushLevanaEarliest != 3 && kiddushLevanaEarliest != 7 {
+		fmt.Fprintf(os.Stderr, "Error, --kiddush-levana-earliest must be 3 or 7: %d\n", kiddushLevanaEarliest)
+		os.Exit(1)
+	}
+	if kiddushLevanaLatest != "15" && kiddushLevanaLatest != "half" {
+		fmt.Fprintf(os.Stderr, "Error, --kiddush-levana-latest must be 15 or half: %s\n", kiddushLevanaLatest)
+		os.Exit(1)
+	}
+
+	if zmanimSetName != "" || len(*zmanSpecs) != 0 || (obs.Elevation > 0 && calOptions.DailyZmanim) {
+		list, err := newZmanimList(zmanimSetName, *zmanSpecs, calOptions.DailyZmanim)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		zmanimList = list
+		calOptions.DailyZmanim = true
+	}
+
+	if !validCity && (calOptions.CandleLighting || calOptions.SunriseSunset || calOptions.DailyZmanim || kiddushLevana_sw) {
 		calOptions.Location = zmanim.LookupCity(defaultCity)
 	}
+	obs.Location = calOptions.Location
 
 	if calOptions.CandleLighting && calOptions.HavdalahDeg == 0.0 && calOptions.HavdalahMins == 0 {
 		calOptions.HavdalahMins = 72
@@ -347,15 +552,43 @@ on the yahrtzeit. Events are printed regardless of the
 			event.LIGHT_CANDLES_TZEIS | event.YOM_TOV_ENDS
 	}
 
+	// Get the remaining positional parameters
+	args := opt.Args()
+
+	if len(args) != 0 && args[0] == "convert" {
+		runConvert(args[1:])
+	}
+	if len(args) != 0 && args[0] == "check" {
+		runCheck(args[1:])
+	}
+	if len(args) != 0 && args[0] == "geo-import" {
+		runGeoImport(args[1:])
+	}
+	if len(args) != 0 && args[0] == "cities" {
+		runCities(args[1:])
+	}
+	if len(args) != 0 && args[0] == "molad" {
+		runMolad(args[1:])
+	}
+	if len(args) != 0 && args[0] == "yearinfo" {
+		runYearInfo(args[1:])
+	}
+
 	if *yahrzeitFileName != "" {
 		calOptions.Yahrzeits = readYahrzeitFile(*yahrzeitFileName)
 	}
 	if *inFileName != "" {
-		calOptions.UserEvents = readUserFile(*inFileName)
+		calOptions.UserEvents, userRules = readUserFile(*inFileName)
+	}
+	if *birthdayFileName != "" {
+		anniversaries = readBirthdayFile(*birthdayFileName)
+	}
+	if *icsFileName != "" {
+		icsEvents = readICSFile(*icsFileName, icsDisplayLocation(&calOptions))
+	}
+	if len(args) == 1 && strings.ContainsAny(args[0], " \t") {
+		args = strings.Fields(args[0]) // e.g. "ט״ו ניסן תשפ״ה" in quotes
 	}
-
-	// Get the remaining positional parameters
-	args := opt.Args()
 
 	switch len(args) {
 	case 0:
@@ -383,12 +616,7 @@ on the yahrtzeit. Events are printed regardless of the
 				fmt.Printf("Default city: %s\n", defaultCity)
 				fmt.Println("Environment variable for default city: HEBCAL_CITY")
 				fmt.Println("Environment variable for default options: HEBCAL_OPTS")
-				os.Exit(0)
-			case "cities":
-				for _, city := range zmanim.AllCities() {
-					fmt.Printf("%s (%.5f,%.5f  %s)\n",
-						city.Name, city.Latitude, city.Longitude, city.TimeZoneId)
-				}
+				fmt.Printf("Config file for default options and profiles: %s\n", configFileName())
 				os.Exit(0)
 			case "copying":
 				fmt.Println(gplv2txt)
@@ -405,6 +633,14 @@ on the yahrtzeit. Events are printed regardless of the
 					theGregMonth = time.Month(gregMonth)
 					theDay, _ = strconv.Atoi(arg0[8:10])
 					rangeType = DAY
+				} else if isGematriya(arg0) {
+					yy, _, err := parseYearArg(arg0)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "%v\n", err)
+						os.Exit(1)
+					}
+					theYear = yy
+					calOptions.IsHebrewYear = true
 				} else {
 					fmt.Fprintf(os.Stderr, "unrecognized command '%s'\n", args[0])
 					fmt.Fprintf(os.Stderr, "Usage: hebcal %s\n", opt.UsageLine())
@@ -413,28 +649,47 @@ on the yahrtzeit. Events are printed regardless of the
 			}
 		}
 	case 2:
-		yy, err := strconv.Atoi(args[1])
+		yy, isHebNum, err := parseYearArg(args[1])
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(1)
 		}
 		theYear = yy
+		if isHebNum {
+			calOptions.IsHebrewYear = true
+		}
 		parseGregOrHebMonth(&calOptions, theYear, args[0], &theGregMonth, &theHebMonth)
 		rangeType = MONTH
 	case 3:
-		dd, err := strconv.Atoi(args[1])
+		yy, isHebNum, err := parseYearArg(args[2])
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(1)
 		}
-		theDay = dd
-		yy, err := strconv.Atoi(args[2])
+		theYear = yy
+		if isHebNum {
+			calOptions.IsHebrewYear = true
+		}
+		monthArg, dayArg, err := monthAndDayArgs(args[0], args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		theDay, err = parseDayArg(dayArg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		parseGregOrHebMonth(&calOptions, theYear, monthArg, &theGregMonth, &theHebMonth)
+		if calOptions.IsHebrewYear {
+			_, err = newHDate(theYear, theHebMonth, theDay)
+		} else {
+			_, err = newGregDate(theYear, theGregMonth, theDay)
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(1)
 		}
-		theYear = yy
-		parseGregOrHebMonth(&calOptions, theYear, args[0], &theGregMonth, &theHebMonth)
 		rangeType = DAY
 	default:
 		opt.PrintUsage(os.Stderr)
@@ -448,7 +703,7 @@ on the yahrtzeit. Events are printed regardless of the
 		fmt.Fprintf(os.Stderr, "Sorry, --today option works only with single-day calendars\n")
 		os.Exit(1)
 	}
-	return calOptions
+	return calOptions, obs
 }
 
 func checkLang() {
@@ -469,6 +724,20 @@ func checkLang() {
 	}
 }
 
+var outputFormats = []string{"text", "ics", "json", "ndjson", "csv", "html"}
+
+func checkFormat() {
+	outputFormat = strings.ToLower(outputFormat)
+	for _, f := range outputFormats {
+		if f == outputFormat {
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown format '%s'; must be one of %s\n",
+		outputFormat, strings.Join(outputFormats, ", "))
+	os.Exit(1)
+}
+
 func parseGregOrHebMonth(calOptions *hebcal.CalOptions, theYear int, arg string, gregMonth *time.Month, hebMonth *hdate.HMonth) {
 	mm, err := strconv.Atoi(arg)
 	if err == nil {
@@ -476,15 +745,17 @@ func parseGregOrHebMonth(calOptions *hebcal.CalOptions, theYear int, arg string,
 			fmt.Fprintf(os.Stderr, "Don't use numbers to specify Hebrew months.\n")
 			os.Exit(1)
 		}
+		if mm < 1 || mm > 12 {
+			fmt.Fprintf(os.Stderr, "Invalid month: %s.\n", arg)
+			os.Exit(1)
+		}
 		*gregMonth = time.Month(mm) /* gregorian month */
 	} else {
-		hm, err := hdate.MonthFromName(arg)
+		/* parseHebrewMonth silently fixes Adar II in a non-leap year */
+		hm, err := parseHebrewMonth(arg, theYear)
 		if err == nil {
 			*hebMonth = hm
 			calOptions.IsHebrewYear = true /* automagically turn it on */
-			if hm == hdate.Adar2 && !hdate.IsLeapYear(theYear) {
-				*hebMonth = hdate.Adar1 /* silently fix this mistake */
-			}
 		} else {
 			fmt.Fprintf(os.Stderr, "Unknown Hebrew month: %s.\n", arg)
 			os.Exit(1)
@@ -492,8 +763,32 @@ func parseGregOrHebMonth(calOptions *hebcal.CalOptions, theYear int, arg string,
 	}
 }
 
+// monthAndDayArgs sorts out the first two of three positional
+// arguments. The usual order is month, day, but Hebrew dates are
+// customarily written day first (ט״ו ניסן), so that order is accepted
+// when the month is given by name.
+func monthAndDayArgs(arg0, arg1 string) (string, string, error) {
+	_, dayErr := parseDayArg(arg1)
+	monthDay := isMonthArg(arg0) && dayErr == nil
+	_, dayErr = parseDayArg(arg0)
+	_, numErr := strconv.Atoi(arg1)
+	dayMonth := isMonthArg(arg1) && numErr != nil && dayErr == nil
+	if monthDay && !dayMonth {
+		if (arg1 == "א׳" || arg1 == "ב׳") && strings.HasPrefix(arg0, "אד") {
+			return "", "", fmt.Errorf("ambiguous arguments '%s %s': day %s of Adar, or the month %s %s? "+
+				"Quote the month name to mean the month", arg0, arg1, arg1, arg0, arg1)
+		}
+		return arg0, arg1, nil
+	} else if dayMonth && !monthDay {
+		return arg1, arg0, nil
+	} else if monthDay && dayMonth {
+		return "", "", fmt.Errorf("ambiguous arguments '%s %s': either could be the month", arg0, arg1)
+	}
+	return "", "", fmt.Errorf("unrecognized month and day: %s %s", arg0, arg1)
+}
+
 func main() {
-	calOptions := handleArgs()
+	calOptions, obs := handleArgs()
 	switch rangeType {
 	case TODAY:
 		calOptions.AddHebrewDates = true
@@ -521,11 +816,33 @@ func main() {
 		panic("Oh, NO! internal error #17q!")
 	}
 
+	userMask := calOptions.Mask // hebcal-go fills in the default mask
 	events, err := hebcal.HebrewCalendar(&calOptions)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	events = adjustForElevation(events, obs, &calOptions)
+	events = replaceDailyZmanim(events, zmanimList, obs, &calOptions)
+	if (calOptions.Sedrot || calOptions.DailySedra) && (userMask == 0 || userMask&HAFTARAH != 0) {
+		events = addHaftarot(events, haftarahMinhag, &calOptions)
+	}
+	var chametz []event.CalEvent
+	if calOptions.CandleLighting && (userMask == 0 || userMask&(CHAMETZ_DEADLINE|BEDIKAT_CHAMETZ) != 0) {
+		events, chametz = chametzEvents(events, obs, &calOptions)
+	}
+	if len(chametz) != 0 || kiddushLevana_sw || len(anniversaries) != 0 || len(userRules) != 0 || len(icsEvents) != 0 {
+		start, end := calendarRange(&calOptions)
+		extra := userRuleEvents(userRules, start, end, calOptions.IL)
+		extra = append(extra, chametz...)
+		if kiddushLevana_sw && (userMask == 0 || userMask&KIDDUSH_LEVANA != 0) {
+			extra = append(extra, kiddushLevanaEvents(start, end, kiddushLevanaEarliest,
+				kiddushLevanaLatest == "half", obs, &calOptions)...)
+		}
+		extra = append(extra, anniversaryEvents(anniversaries, start, end)...)
+		extra = append(extra, icsEventsInRange(icsEvents, start, end, &calOptions)...)
+		events = mergeEvents(events, extra, &calOptions)
+	}
 
 	if isTodayChag_sw {
 		status, reason := isTodayChag(&calOptions, events)
@@ -535,10 +852,33 @@ func main() {
 		os.Exit(status)
 	}
 
-	for _, ev := range events {
-		gregDate := printGregDate(ev.GetDate())
-		desc := ev.Render(lang)
-		fmt.Printf("%s%s\n", gregDate, desc)
+	switch {
+	case shabbatTable_sw:
+		err = writeShabbatTable(os.Stdout, events, obs, &calOptions)
+	case grid_sw:
+		err = writeGrid(os.Stdout, events, &calOptions, isTerminal(os.Stdout))
+	case eventTemplate != nil:
+		err = writeTemplate(os.Stdout, events, eventTemplate)
+	case outputFormat == "ics":
+		err = writeICalendar(os.Stdout, events, &calOptions)
+	case outputFormat == "json":
+		err = writeJSON(os.Stdout, events)
+	case outputFormat == "ndjson":
+		err = writeNDJSON(os.Stdout, events)
+	case outputFormat == "csv":
+		err = writeCSV(os.Stdout, events, &calOptions)
+	case outputFormat == "html":
+		err = writeHTML(os.Stdout, events, &calOptions)
+	default:
+		for _, ev := range events {
+			gregDate := printGregDate(ev.GetDate())
+			desc := ev.Render(lang)
+			fmt.Printf("%s%s\n", gregDate, desc)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
 	}
 }
 
@@ -620,24 +960,7 @@ func isTodayChag(calOptions *hebcal.CalOptions, events []event.CalEvent) (int, s
 func printGregDate(hd hdate.HDate) string {
 	str := ""
 	if !noGreg_sw {
-		year, month, day := hd.Greg()
-		d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
-		if gregDateOutputFormatCode_sw == ISO {
-			timeStr := d.Format(time.RFC3339)
-			idx := strings.IndexRune(timeStr, 'T')
-			str += timeStr[:idx]
-		} else {
-			if gregDateOutputFormatCode_sw == EURO {
-				str += fmt.Sprintf("%d.%d.", day, month) /* dd.mm.yyyy */
-			} else {
-				str += fmt.Sprintf("%d/%d/", month, day) /* mm/dd/yyyy */
-			}
-			if yearDigits_sw {
-				str += strconv.Itoa(year % 100)
-			} else {
-				str += strconv.Itoa(year)
-			}
-		}
+		str += formatGregDate(hd)
 		if tabs_sw {
 			str += "\t"
 		} else {
@@ -651,6 +974,42 @@ func printGregDate(hd hdate.HDate) string {
 	return str
 }
 
+// formatGregDate formats the Gregorian date of hd according to the
+// -e, -g and -y switches.
+func formatGregDate(hd hdate.HDate) string {
+	year, month, day := hd.Greg()
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if gregDateOutputFormatCode_sw == ISO {
+		timeStr := d.Format(time.RFC3339)
+		idx := strings.IndexRune(timeStr, 'T')
+		return timeStr[:idx]
+	}
+	str := ""
+	if gregDateOutputFormatCode_sw == EURO {
+		str += fmt.Sprintf("%d.%d.", day, month) /* dd.mm.yyyy */
+	} else {
+		str += fmt.Sprintf("%d/%d/", month, day) /* mm/dd/yyyy */
+	}
+	if yearDigits_sw {
+		str += strconv.Itoa(year % 100)
+	} else {
+		str += strconv.Itoa(year)
+	}
+	return str
+}
+
+// eventTitle returns the rendered description of ev without the
+// clock time that TimedEvent.Render appends.
+func eventTitle(ev event.CalEvent, locale string) string {
+	desc := ev.Render(locale)
+	if _, ok := ev.(hebcal.TimedEvent); ok {
+		if idx := strings.LastIndex(desc, ": "); idx != -1 {
+			desc = desc[:idx]
+		}
+	}
+	return desc
+}
+
 func intAbs(x int) int {
 	if x < 0 {
 		return -x
@@ -668,6 +1027,22 @@ var usageSummary = `
 hebcal help    -- Print this message.
 hebcal info    -- Print version and localization data.
 hebcal cities  -- Print a list of available cities.
+hebcal cities [--country CC] [--search] QUERY -- Find cities by name,
+                  ignoring case and accents and allowing for typos.
+hebcal convert [--after-sunset] [--lang LANG] DATE -- Convert a Gregorian
+                  date (YYYY-MM-DD or MM/DD/YYYY) to a Hebrew date, or a
+                  Hebrew date ("15 Nisan 5784") to a Gregorian date.
+                  Reads one date per line from stdin if DATE is omitted.
+hebcal check [-I|-Y|-B] FILE... -- Check -I, -Y and -B input files
+                  and report every problem.
+hebcal molad [--format FORMAT] [--tz TZID] [YEAR] -- Print the molad of
+                  every month of Hebrew year YEAR, with Rosh Chodesh
+                  and the dechiyot that postponed Rosh Hashana.
+hebcal yearinfo [YEAR] -- Print the keviah, length, cycles and
+                  doubled parshiyot of Hebrew year YEAR.
+hebcal geo-import FILE... -- Import a GeoNames cities file (e.g.
+                  cities15000.txt) and US ZIP codes (CSV with a header)
+                  for --geonameid and --zip.
 hebcal warranty -- Tells you how there's NO WARRANTY for hebcal.
 hebcal copying -- Prints the details of the GNU copyright.
 
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/molad"
+	"github.com/pborman/getopt/v2"
+)
+
+// moladRow is one month of "hebcal molad YEAR". Hours, Minutes and
+// Chalakim are as in molad.Molad, on the clock of Jerusalem mean time.
+type moladRow struct {
+	Month       string        `json:"month"`
+	Weekday     string        `json:"weekday"` // the Hebrew day, which begins at 6 PM
+	Hours       int           `json:"hours"`
+	Minutes     int           `json:"minutes"`
+	Chalakim    int           `json:"chalakim"`
+	Time        time.Time     `json:"time"` // in the --tz time zone
+	RoshChodesh []hdate.HDate `json:"-"`
+	Dates       []string      `json:"roshChodesh"` // RoshChodesh as YYYY-MM-DD
+	Notes       []string      `json:"notes,omitempty"`
+}
+
+const chalakimPerHour = 1080
+
+// moladHebrewDay returns the day of the molad in the Hebrew calendar,
+// where each day begins at 6 PM, and the chalakim since it began.
+func moladHebrewDay(m molad.Molad) (int64, int) {
+	abs := m.Date.Abs()
+	hours := m.Hours + 6 // molad.Molad's hours are on the clock
+	if m.Hours >= 18 {
+		abs++
+		hours -= 24
+	}
+	return abs, hours*chalakimPerHour + m.Minutes*18 + m.Chalakim
+}
+
+// roshHashanaDechiyot returns the postponements (dechiyot) that moved
+// Rosh Hashana of year from the day of the molad of Tishrei.
+func roshHashanaDechiyot(year int) []string {
+	abs, parts := moladHebrewDay(molad.New(year, hdate.Tishrei))
+	dechiyot := make([]string, 0, 2)
+	weekday := hdate.FromRD(abs).Weekday()
+	switch {
+	case parts >= 18*chalakimPerHour:
+		dechiyot = append(dechiyot, "molad zaken")
+		abs++
+	case !hdate.IsLeapYear(year) && weekday == time.Tuesday && parts >= 9*chalakimPerHour+204:
+		dechiyot = append(dechiyot, "GaTaRaD")
+		abs++
+	case hdate.IsLeapYear(year-1) && weekday == time.Monday && parts >= 15*chalakimPerHour+589:
+		dechiyot = append(dechiyot, "BeTUTaKPaT")
+		abs++
+	}
+	switch hdate.FromRD(abs).Weekday() {
+	case time.Sunday, time.Wednesday, time.Friday:
+		dechiyot = append(dechiyot, "Lo ADU Rosh")
+	}
+	return dechiyot
+}
+
+// roshChodeshDays returns the days of Rosh Chodesh for the month that
+// begins on hd: the 30th of the previous month, if it has one, and
+// the 1st.
+func roshChodeshDays(hd hdate.HDate) []hdate.HDate {
+	prev := hd.Prev()
+	if prev.Day() == 30 {
+		return []hdate.HDate{prev, hd}
+	}
+	return []hdate.HDate{hd}
+}
+
+// moladRows returns the molad of each month of the Hebrew year.
+func moladRows(year int, loc *time.Location) []moladRow {
+	rows := make([]moladRow, 0, 13)
+	for hd := hdate.New(year, hdate.Tishrei, 1); hd.Year() == year; hd = hdate.FromRD(hd.Abs() + int64(hd.DaysInMonth())) {
+		m := molad.New(hd.Year(), hd.Month())
+		abs, _ := moladHebrewDay(m)
+		row := moladRow{
+			Month:       hd.MonthName("en"),
+			Weekday:     hdate.FromRD(abs).Weekday().String()[0:3],
+			Hours:       m.Hours,
+			Minutes:     m.Minutes,
+			Chalakim:    m.Chalakim,
+			Time:        moladTime(hd).In(loc).Truncate(time.Second),
+			RoshChodesh: roshChodeshDays(hd),
+		}
+		for _, day := range row.RoshChodesh {
+			year, month, d := day.Greg()
+			row.Dates = append(row.Dates, time.Date(year, month, d, 0, 0, 0, 0, time.UTC).Format("2006-01-02"))
+		}
+		if hd.Month() == hdate.Tishrei {
+			row.Notes = roshHashanaDechiyot(year)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+var moladTableHeader = []string{"Month", "Weekday", "Hours", "Minutes", "Chalakim",
+	"Time", "Rosh Chodesh", "Notes"}
+
+func moladRecords(rows []moladRow, hour24 bool) [][]string {
+	timeFormat := "3:04:05 PM MST"
+	if hour24 {
+		timeFormat = "15:04:05 MST"
+	}
+	records := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		days := make([]string, len(row.RoshChodesh))
+		for i, hd := range row.RoshChodesh {
+			days[i] = hd.Weekday().String()[0:3] + " " + formatGregDate(hd)
+		}
+		records = append(records, []string{
+			row.Month,
+			row.Weekday,
+			strconv.Itoa(row.Hours),
+			strconv.Itoa(row.Minutes),
+			strconv.Itoa(row.Chalakim),
+			formatGregDate(hdate.FromTime(row.Time)) + " " + row.Time.Format(timeFormat),
+			strings.Join(days, ", "),
+			strings.Join(row.Notes, ", "),
+		})
+	}
+	return records
+}
+
+func writeMoladText(out io.Writer, rows []moladRow, hour24 bool) error {
+	records := append([][]string{moladTableHeader}, moladRecords(rows, hour24)...)
+	widths := make([]int, len(moladTableHeader))
+	for _, record := range records {
+		for i, str := range record {
+			if n := displayWidth(str); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	for _, record := range records {
+		var sb strings.Builder
+		for i, str := range record {
+			sb.WriteString(padRight(str, widths[i]+2))
+		}
+		if _, err := fmt.Fprintln(out, strings.TrimRight(sb.String(), " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMoladCSV(out io.Writer, rows []moladRow, hour24 bool) error {
+	if _, err := io.WriteString(out, "\ufeff"); err != nil {
+		return err
+	}
+	w := csv.NewWriter(out)
+	w.UseCRLF = true
+	if err := w.Write(moladTableHeader); err != nil {
+		return err
+	}
+	if err := w.WriteAll(moladRecords(rows, hour24)); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func writeMoladJSON(out io.Writer, rows []moladRow) error {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+// runMolad implements "hebcal molad [--format FORMAT] [--tz TZID] [YEAR]".
+func runMolad(args []string) {
+	opt := getopt.New()
+	opt.SetProgram("hebcal molad")
+	opt.SetParameters("[YEAR]")
+	format := opt.StringLong("format", 0, "", "Output format (text, json, csv); defaults to hebcal's --format", "FORMAT")
+	tzid := opt.StringLong("tz", 0, "Asia/Jerusalem", "Show the civil time of each molad in time zone TZID", "TZID")
+	hour24 := opt.BoolLong("24hour", 'E', "Output 24-hour times (e.g. 18:37 instead of 6:37)")
+	if err := opt.Getopt(append([]string{"molad"}, args...), nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		opt.PrintUsage(os.Stderr)
+		os.Exit(1)
+	}
+	year := hdate.FromTime(time.Now()).Year()
+	if rest := opt.Args(); len(rest) > 1 {
+		fmt.Fprintf(os.Stderr, "Error, too many arguments: %s\n", strings.Join(rest, " "))
+		os.Exit(1)
+	} else if len(rest) == 1 {
+		var err error
+		year, err = strconv.Atoi(rest[0])
+		if err != nil || year < 1 {
+			fmt.Fprintf(os.Stderr, "Error, invalid Hebrew year: %s\n", rest[0])
+			os.Exit(1)
+		}
+	}
+	if *format == "" {
+		*format = outputFormat // e.g. hebcal --format=json molad
+	}
+	loc, err := time.LoadLocation(*tzid)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error, %v\n", err)
+		os.Exit(1)
+	}
+	rows := moladRows(year, loc)
+	switch strings.ToLower(*format) {
+	case "text":
+		err = writeMoladText(os.Stdout, rows, *hour24)
+	case "json":
+		err = writeMoladJSON(os.Stdout, rows)
+	case "csv":
+		err = writeMoladCSV(os.Stdout, rows, *hour24)
+	default:
+		fmt.Fprintf(os.Stderr, "Error, unknown format %s (expected text, json or csv)\n", *format)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/hebcal/greg"
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+)
+
+// monthSpan is the first and last day of one Gregorian or Hebrew month
+type monthSpan struct {
+	first hdate.HDate
+	last  hdate.HDate
+}
+
+// calendarRange returns the first and last day covered by calOptions,
+// mirroring the way hebcal.HebrewCalendar interprets Year and NumYears.
+func calendarRange(calOptions *hebcal.CalOptions) (hdate.HDate, hdate.HDate) {
+	if (calOptions.Start != hdate.HDate{}) {
+		return calOptions.Start, calOptions.End
+	}
+	numYears := calOptions.NumYears
+	if numYears < 1 {
+		numYears = 1
+	}
+	if calOptions.IsHebrewYear {
+		start := hdate.New(calOptions.Year, hdate.Tishrei, 1)
+		end := hdate.New(calOptions.Year+numYears, hdate.Tishrei, 1).Prev()
+		return start, end
+	}
+	start := hdate.FromGregorian(calOptions.Year, time.January, 1)
+	end := hdate.FromGregorian(calOptions.Year+numYears, time.January, 1).Prev()
+	return start, end
+}
+
+// calendarMonths splits the days from start to end into whole months,
+// Hebrew months if hebrew is true and Gregorian months otherwise.
+func calendarMonths(start, end hdate.HDate, hebrew bool) []monthSpan {
+	months := make([]monthSpan, 0, 13)
+	var first hdate.HDate
+	if hebrew {
+		first = hdate.New(start.Year(), start.Month(), 1)
+	} else {
+		year, month, _ := start.Greg()
+		first = hdate.FromGregorian(year, month, 1)
+	}
+	for first.Abs() <= end.Abs() {
+		var last hdate.HDate
+		if hebrew {
+			last = hdate.New(first.Year(), first.Month(), first.DaysInMonth())
+		} else {
+			year, month, _ := first.Greg()
+			last = hdate.FromGregorian(year, month, greg.DaysIn(month, year))
+		}
+		months = append(months, monthSpan{first: first, last: last})
+		first = last.Next()
+	}
+	return months
+}
+
+// eventsByDay indexes events by R.D. date.
+func eventsByDay(events []event.CalEvent) map[int64][]event.CalEvent {
+	days := make(map[int64][]event.CalEvent)
+	for _, ev := range events {
+		hd := ev.GetDate()
+		abs := hd.Abs()
+		days[abs] = append(days[abs], ev)
+	}
+	return days
+}
+
+// mergeEvents inserts extra events into the date-ordered events returned
+// by hebcal.HebrewCalendar. On each day, extra events follow the existing
+// ones. When -D is in effect, a Hebrew date is added to days that had no
+// events before.
+func mergeEvents(events []event.CalEvent, extra []event.CalEvent, calOptions *hebcal.CalOptions) []event.CalEvent {
+	if len(extra) == 0 {
+		return events
+	}
+	extraByDay := eventsByDay(extra)
+	merged := make([]event.CalEvent, 0, len(events)+len(extra))
+	appendDay := func(abs int64, hasEvents bool) {
+		evs, ok := extraByDay[abs]
+		if !ok {
+			return
+		}
+		if !hasEvents && (calOptions.AddHebrewDates || calOptions.AddHebrewDatesForEvents) {
+			merged = append(merged, event.NewHebrewDateEvent(hdate.FromRD(abs)))
+		}
+		merged = append(merged, evs...)
+		delete(extraByDay, abs)
+	}
+	// days before, between and after the existing events
+	pending := sortedDays(extraByDay)
+	for i, ev := range events {
+		abs := eventAbs(ev)
+		for len(pending) != 0 && pending[0] < abs {
+			appendDay(pending[0], false)
+			pending = pending[1:]
+		}
+		merged = append(merged, ev)
+		if i+1 == len(events) || eventAbs(events[i+1]) != abs {
+			appendDay(abs, true)
+			if len(pending) != 0 && pending[0] == abs {
+				pending = pending[1:]
+			}
+		}
+	}
+	for _, abs := range pending {
+		appendDay(abs, false)
+	}
+	return merged
+}
+
+func eventAbs(ev event.CalEvent) int64 {
+	hd := ev.GetDate()
+	return hd.Abs()
+}
+
+// sortedDays returns the keys of days in ascending order.
+func sortedDays(days map[int64][]event.CalEvent) []int64 {
+	keys := make([]int64, 0, len(days))
+	for abs := range days {
+		keys = append(keys, abs)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func isHebrewLocale(locale string) bool {
+	return locale == "he" || locale == "he-x-nonikud"
+}
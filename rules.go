@@ -0,0 +1,411 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hebcal/greg"
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+)
+
+// ruleKind identifies the date specification of a -I file line
+type ruleKind int
+
+const (
+	ruleHebrew     ruleKind = iota // MMMM DD
+	ruleHebrewLast                 // MMMM last
+	ruleGregorian                  // MM/DD
+	ruleNthWeekday                 // ORDINAL WEEKDAY of MONTH
+	ruleHoliday                    // HOLIDAY +N or HOLIDAY -N
+)
+
+// userRule is one recurring event from a -I file
+type userRule struct {
+	Kind     ruleKind
+	HMonth   hdate.HMonth // ruleHebrew, ruleHebrewLast, ruleNthWeekday
+	GMonth   time.Month   // ruleGregorian, ruleNthWeekday
+	Day      int          // ruleHebrew, ruleGregorian
+	Nth      int          // 1 to 5, or -1 for last
+	Weekday  time.Weekday // ruleNthWeekday
+	Holiday  string       // ruleHoliday
+	Offset   int          // ruleHoliday, in days
+	Duration int          // number of days, at least 1
+	Desc     string
+}
+
+// simple returns true for a fixed Hebrew date lasting one day, which
+// hebcal.HebrewCalendar can generate itself as a hebcal.UserEvent.
+func (r userRule) simple() bool {
+	return r.Kind == ruleHebrew && r.Duration == 1
+}
+
+var ruleOrdinals = map[string]int{
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "last": -1,
+	"1st": 1, "2nd": 2, "3rd": 3, "4th": 4, "5th": 5,
+}
+
+var ruleOffsetRegex = regexp.MustCompile(`^[+-]\d+$`)
+var ruleGregRegex = regexp.MustCompile(`^(\d\d?)/(\d\d?)$`)
+
+// parseUserRule parses one line of a -I file. The formats are:
+//
+//	MMMM DD Description              fixed Hebrew date
+//	MMMM last Description            last day of a Hebrew month
+//	MM/DD Description                fixed Gregorian date
+//	ORDINAL WEEKDAY of MONTH Desc    e.g. first Shabbat of Cheshvan
+//	HOLIDAY +N Description           e.g. Pesach I -30
+//
+// Any date may be followed by "for N days" for a multi-day event.
+func parseUserRule(line string) (userRule, error) {
+	tokens := strings.Fields(line)
+	if len(tokens) < 2 {
+		return userRule{}, fieldErrorf(0, "invalid format")
+	}
+	var r userRule
+	var n int
+	var err error
+	switch {
+	case isHolidayRule(tokens):
+		r, n, err = parseHolidayRule(tokens)
+	case ruleOrdinals[strings.ToLower(tokens[0])] != 0:
+		r, n, err = parseNthWeekdayRule(tokens)
+	case ruleGregRegex.MatchString(tokens[0]):
+		r, n, err = parseGregorianRule(tokens)
+	default:
+		r, n, err = parseHebrewRule(tokens)
+	}
+	if err != nil {
+		return userRule{}, err
+	}
+	tokens = tokens[n:]
+	r.Duration = 1
+	if len(tokens) >= 3 && strings.EqualFold(tokens[0], "for") &&
+		(strings.EqualFold(tokens[2], "days") || strings.EqualFold(tokens[2], "day")) {
+		duration, err := strconv.Atoi(tokens[1])
+		if err != nil || duration < 1 {
+			return userRule{}, fieldErrorf(n+1, "invalid duration: %s", tokens[1])
+		}
+		r.Duration = duration
+		tokens = tokens[3:]
+	}
+	if len(tokens) == 0 {
+		return userRule{}, fieldErrorf(len(strings.Fields(line)), "missing description")
+	}
+	r.Desc = strings.Join(tokens, " ")
+	return r, nil
+}
+
+// parseRuleHebMonth parses a Hebrew month name at tokens[field],
+// allowing "Adar I" and "Adar II" as two words. When dayFollows is set,
+// the second word is only read as part of the month if a day comes
+// after it, so that "Adar 1 First of Adar" is still the 1st of Adar.
+// It returns the month and the number of tokens consumed.
+func parseRuleHebMonth(tokens []string, field int, dayFollows bool) (hdate.HMonth, int, error) {
+	tokens = tokens[field:]
+	if len(tokens) >= 2 && (!dayFollows || (len(tokens) >= 3 && isRuleDay(tokens[2]))) {
+		switch tokens[1] {
+		case "I", "1", "א׳", "א":
+			if month, err := hdate.MonthFromName(tokens[0] + "1"); err == nil && month == hdate.Adar1 {
+				return month, 2, nil
+			}
+		case "II", "2", "ב׳", "ב":
+			if month, err := hdate.MonthFromName(tokens[0] + "2"); err == nil && month == hdate.Adar2 {
+				return month, 2, nil
+			}
+		}
+	}
+	month, err := hdate.MonthFromName(strings.TrimPrefix(tokens[0], "ב"))
+	if err != nil {
+		return 0, 0, fieldErrorf(field, "invalid month: %s", tokens[0])
+	}
+	return month, 1, nil
+}
+
+// isRuleDay returns true if str is a day of a Hebrew month or "last".
+func isRuleDay(str string) bool {
+	if strings.EqualFold(str, "last") {
+		return true
+	}
+	day, err := parseNumber(str)
+	return err == nil && day >= 1 && day <= 30
+}
+
+// parseRuleGregMonth accepts English month names, their three-letter
+// abbreviations, or month numbers.
+func parseRuleGregMonth(str string) (time.Month, bool) {
+	if mm, err := strconv.Atoi(str); err == nil {
+		return time.Month(mm), mm >= 1 && mm <= 12
+	}
+	lower := strings.ToLower(str)
+	for month := time.January; month <= time.December; month++ {
+		name := strings.ToLower(month.String())
+		if lower == name || lower == name[0:3] {
+			return month, true
+		}
+	}
+	return 0, false
+}
+
+func parseRuleWeekday(str string) (time.Weekday, bool) {
+	lower := strings.ToLower(str)
+	switch lower {
+	case "shabbat", "shabbos":
+		return time.Saturday, true
+	}
+	for dow := time.Sunday; dow <= time.Saturday; dow++ {
+		name := strings.ToLower(dow.String())
+		if lower == name || lower == name[0:3] {
+			return dow, true
+		}
+	}
+	return 0, false
+}
+
+func parseHebrewRule(tokens []string) (userRule, int, error) {
+	month, n, err := parseRuleHebMonth(tokens, 0, true)
+	if err != nil {
+		return userRule{}, 0, err
+	}
+	if len(tokens) <= n {
+		return userRule{}, 0, fieldErrorf(n, "invalid format")
+	}
+	if strings.EqualFold(tokens[n], "last") {
+		return userRule{Kind: ruleHebrewLast, HMonth: month}, n + 1, nil
+	}
+	day, err := parseNumber(tokens[n])
+	if err != nil || day < 1 || day > 30 {
+		return userRule{}, 0, fieldErrorf(n, "invalid day: %s", tokens[n])
+	}
+	if day > maxDaysInMonth(month) {
+		return userRule{}, 0, fieldErrorf(n, "invalid day %d for %s", day, month.String())
+	}
+	return userRule{Kind: ruleHebrew, HMonth: month, Day: day}, n + 1, nil
+}
+
+func parseGregorianRule(tokens []string) (userRule, int, error) {
+	m := ruleGregRegex.FindStringSubmatch(tokens[0])
+	mm, _ := strconv.Atoi(m[1])
+	day, _ := strconv.Atoi(m[2])
+	month := time.Month(mm)
+	if month < time.January || month > time.December {
+		return userRule{}, 0, fieldErrorf(0, "invalid month: %s", m[1])
+	}
+	// allow Feb 29, observed only in leap years
+	if day < 1 || day > greg.DaysIn(month, 2000) {
+		return userRule{}, 0, fieldErrorf(0, "invalid day %d for %s", day, month.String())
+	}
+	return userRule{Kind: ruleGregorian, GMonth: month, Day: day}, 1, nil
+}
+
+func parseNthWeekdayRule(tokens []string) (userRule, int, error) {
+	if len(tokens) < 4 || !strings.EqualFold(tokens[2], "of") {
+		return userRule{}, 0, fieldErrorf(0, "expected ORDINAL WEEKDAY of MONTH")
+	}
+	r := userRule{Kind: ruleNthWeekday, Nth: ruleOrdinals[strings.ToLower(tokens[0])]}
+	dow, ok := parseRuleWeekday(tokens[1])
+	if !ok {
+		return userRule{}, 0, fieldErrorf(1, "invalid weekday: %s", tokens[1])
+	}
+	r.Weekday = dow
+	if month, ok := parseRuleGregMonth(tokens[3]); ok {
+		r.GMonth = month
+		return r, 4, nil
+	}
+	month, n, err := parseRuleHebMonth(tokens, 3, false)
+	if err != nil {
+		return userRule{}, 0, err
+	}
+	r.HMonth = month
+	return r, 3 + n, nil
+}
+
+// isHolidayRule returns true if tokens begin with a holiday name
+// followed by a signed offset.
+func isHolidayRule(tokens []string) bool {
+	for i := 1; i < len(tokens); i++ {
+		if ruleOffsetRegex.MatchString(tokens[i]) {
+			return knownHolidayName(strings.Join(tokens[:i], " "))
+		}
+	}
+	return false
+}
+
+func parseHolidayRule(tokens []string) (userRule, int, error) {
+	i := 1
+	for !ruleOffsetRegex.MatchString(tokens[i]) {
+		i++
+	}
+	offset, err := strconv.Atoi(tokens[i])
+	if err != nil {
+		return userRule{}, 0, fieldErrorf(i, "invalid offset: %s", tokens[i])
+	}
+	return userRule{
+		Kind:    ruleHoliday,
+		Holiday: strings.Join(tokens[:i], " "),
+		Offset:  offset,
+	}, i + 1, nil
+}
+
+var holidayNames map[string]bool
+
+// knownHolidayName returns true if name matches a holiday in a leap
+// or non-leap year, in Israel or the Diaspora.
+func knownHolidayName(name string) bool {
+	if holidayNames == nil {
+		holidayNames = make(map[string]bool)
+		for _, hyear := range []int{5784, 5785} {
+			for _, il := range []bool{false, true} {
+				for _, ev := range hebcal.GetHolidaysForYear(hyear, il) {
+					holidayNames[strings.ToLower(ev.Desc)] = true
+					holidayNames[strings.ToLower(ev.Basename())] = true
+					holidayNames[strings.ToLower(ev.Render(lang))] = true
+				}
+			}
+		}
+	}
+	return holidayNames[strings.ToLower(name)]
+}
+
+// holidayDate finds the first occurrence of the named holiday in hyear.
+// A name such as "Pesach I" is matched exactly; a basename such as
+// "Pesach" matches the first day of the holiday, but not its Erev.
+func holidayDate(name string, hyear int, il bool) (hdate.HDate, bool) {
+	var exact, base hdate.HDate
+	earlier := func(found, hd hdate.HDate) bool {
+		return (found == hdate.HDate{}) || hd.Abs() < found.Abs()
+	}
+	for _, ev := range hebcal.GetHolidaysForYear(hyear, il) {
+		if strings.EqualFold(name, ev.Desc) || strings.EqualFold(name, ev.Render(lang)) {
+			if earlier(exact, ev.Date) {
+				exact = ev.Date
+			}
+		} else if strings.EqualFold(name, ev.Basename()) && (ev.Flags&event.EREV) == 0 {
+			if earlier(base, ev.Date) {
+				base = ev.Date
+			}
+		}
+	}
+	if (exact != hdate.HDate{}) {
+		return exact, true
+	}
+	return base, base != hdate.HDate{}
+}
+
+// nthWeekday returns the nth dow on or after first and on or before
+// last, counting back from last when n is -1.
+func nthWeekday(first, last hdate.HDate, n int, dow time.Weekday) (hdate.HDate, bool) {
+	if n < 0 {
+		hd := hdate.FromRD(hdate.DayOnOrBefore(dow, last.Abs()))
+		return hd, true
+	}
+	abs := hdate.DayOnOrBefore(dow, first.Abs()+6) + int64(7*(n-1))
+	return hdate.FromRD(abs), abs <= last.Abs()
+}
+
+// maxDaysInMonth returns 30 for Hebrew months that have 30 days in at
+// least some years, and 29 for those that never do.
+func maxDaysInMonth(month hdate.HMonth) int {
+	switch month {
+	case hdate.Iyyar, hdate.Tamuz, hdate.Elul, hdate.Tevet, hdate.Adar2:
+		return 29
+	}
+	return 30
+}
+
+// hebrewMonthInYear maps Adar II to Adar in a non-leap year.
+func hebrewMonthInYear(month hdate.HMonth, hyear int) hdate.HMonth {
+	if month == hdate.Adar2 && !hdate.IsLeapYear(hyear) {
+		return hdate.Adar1
+	}
+	return month
+}
+
+// occurrence returns the first day of r in the given Hebrew or, for
+// Gregorian rules, Gregorian year.
+func (r userRule) occurrence(year int, il bool) (hdate.HDate, bool) {
+	switch r.Kind {
+	case ruleHebrew:
+		month := hebrewMonthInYear(r.HMonth, year)
+		// Watch for ShortKislev and LongCheshvan
+		if r.Day > hdate.DaysInMonth(month, year) {
+			return hdate.HDate{}, false
+		}
+		return hdate.New(year, month, r.Day), true
+	case ruleHebrewLast:
+		month := hebrewMonthInYear(r.HMonth, year)
+		return hdate.New(year, month, hdate.DaysInMonth(month, year)), true
+	case ruleGregorian:
+		if r.Day > greg.DaysIn(r.GMonth, year) {
+			return hdate.HDate{}, false
+		}
+		return hdate.FromGregorian(year, r.GMonth, r.Day), true
+	case ruleNthWeekday:
+		if r.GMonth != 0 {
+			first := hdate.FromGregorian(year, r.GMonth, 1)
+			last := hdate.FromGregorian(year, r.GMonth, greg.DaysIn(r.GMonth, year))
+			return nthWeekday(first, last, r.Nth, r.Weekday)
+		}
+		month := hebrewMonthInYear(r.HMonth, year)
+		first := hdate.New(year, month, 1)
+		last := hdate.New(year, month, hdate.DaysInMonth(month, year))
+		return nthWeekday(first, last, r.Nth, r.Weekday)
+	case ruleHoliday:
+		hd, ok := holidayDate(r.Holiday, year, il)
+		if !ok {
+			return hdate.HDate{}, false
+		}
+		return hdate.FromRD(hd.Abs() + int64(r.Offset)), true
+	}
+	return hdate.HDate{}, false
+}
+
+// isGregorian returns true if r recurs every Gregorian year.
+func (r userRule) isGregorian() bool {
+	return r.Kind == ruleGregorian || (r.Kind == ruleNthWeekday && r.GMonth != 0)
+}
+
+// userRuleEvents expands rules into events from start to end. Each day
+// of a multi-day event is annotated with its position, e.g. "(day 2 of 3)".
+func userRuleEvents(rules []userRule, start, end hdate.HDate, il bool) []event.CalEvent {
+	events := make([]event.CalEvent, 0, len(rules))
+	startAbs, endAbs := start.Abs(), end.Abs()
+	startGregYear, _, _ := start.Greg()
+	endGregYear, _, _ := end.Greg()
+	for _, r := range rules {
+		// begin a year early for events that span the start of the range
+		first, last := start.Year()-1, end.Year()+1
+		if r.isGregorian() {
+			first, last = startGregYear-1, endGregYear
+		}
+		for year := first; year <= last; year++ {
+			if year < 1 {
+				continue
+			}
+			hd, ok := r.occurrence(year, il)
+			if !ok {
+				continue
+			}
+			for i := 0; i < r.Duration; i++ {
+				abs := hd.Abs() + int64(i)
+				if abs < startAbs || abs > endAbs {
+					continue
+				}
+				desc := r.Desc
+				if r.Duration > 1 {
+					desc = fmt.Sprintf("%s (day %d of %d)", r.Desc, i+1, r.Duration)
+				}
+				events = append(events, event.HolidayEvent{
+					Date:  hdate.FromRD(abs),
+					Desc:  desc,
+					Flags: event.USER_EVENT,
+				})
+			}
+		}
+	}
+	return events
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hebcal/hdate"
+)
+
+func TestParseUserRule(t *testing.T) {
+	tests := []struct {
+		line string
+		want userRule
+	}{
+		{"Adar 1 First of Adar party", // plain Adar is Adar II in a leap year
+			userRule{Kind: ruleHebrew, HMonth: hdate.Adar2, Day: 1, Duration: 1, Desc: "First of Adar party"}},
+		{"Adar 2 14 Purim Katan",
+			userRule{Kind: ruleHebrew, HMonth: hdate.Adar2, Day: 14, Duration: 1, Desc: "Purim Katan"}},
+		{"Adar I 14 Purim Katan",
+			userRule{Kind: ruleHebrew, HMonth: hdate.Adar1, Day: 14, Duration: 1, Desc: "Purim Katan"}},
+		{"Adar II last Party",
+			userRule{Kind: ruleHebrewLast, HMonth: hdate.Adar2, Duration: 1, Desc: "Party"}},
+		{"Cheshvan 30 Not every year",
+			userRule{Kind: ruleHebrew, HMonth: hdate.Cheshvan, Day: 30, Duration: 1, Desc: "Not every year"}},
+		{"Kislev 25 for 8 days Chanukah party",
+			userRule{Kind: ruleHebrew, HMonth: hdate.Kislev, Day: 25, Duration: 8, Desc: "Chanukah party"}},
+		{"7/4 Fireworks",
+			userRule{Kind: ruleGregorian, GMonth: time.July, Day: 4, Duration: 1, Desc: "Fireworks"}},
+		{"first Shabbat of Cheshvan Kiddush",
+			userRule{Kind: ruleNthWeekday, HMonth: hdate.Cheshvan, Nth: 1, Weekday: time.Saturday, Duration: 1, Desc: "Kiddush"}},
+		{"last Sunday of November Brunch",
+			userRule{Kind: ruleNthWeekday, GMonth: time.November, Nth: -1, Weekday: time.Sunday, Duration: 1, Desc: "Brunch"}},
+		{"Pesach I -30 Start studying",
+			userRule{Kind: ruleHoliday, Holiday: "Pesach I", Offset: -30, Duration: 1, Desc: "Start studying"}},
+	}
+	for _, test := range tests {
+		got, err := parseUserRule(test.line)
+		if err != nil {
+			t.Errorf("parseUserRule(%q): %v", test.line, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseUserRule(%q) = %+v, want %+v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestParseUserRuleErrors(t *testing.T) {
+	for _, line := range []string{
+		"Adar",
+		"Nisan 31 Too late",
+		"Iyyar 30 Never",
+		"Adar II 30 Never",
+		"Foo 1 Not a month",
+		"13/1 Not a month",
+		"2/30 Not a day",
+		"Nisan 1",
+		"Nisan 1 for 0 days Nothing",
+	} {
+		if r, err := parseUserRule(line); err == nil {
+			t.Errorf("parseUserRule(%q) = %+v, want error", line, r)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+	"github.com/hebcal/hebcal-go/sedra"
+)
+
+// shabbatRow is one line of the --shabbat-table: a Shabbat, or a Yom
+// Tov that doesn't fall on Shabbat. The times through EarliestMincha
+// are on the evening before Date.
+type shabbatRow struct {
+	Date           hdate.HDate
+	Title          string // the parsha or holiday
+	CandleLighting time.Time
+	Sunset         time.Time
+	Mincha         time.Time // --mincha-mins before candle lighting
+	EarliestMincha time.Time // Mincha Gedolah on the day before
+	ShemaMGA       time.Time
+	ShemaGRA       time.Time
+	MinchaGedolah  time.Time
+	Havdalah       time.Time
+	YomTovCandles  time.Time // candle lighting after dark for a Yom Tov that follows
+}
+
+func shabbatTableHeader() []string {
+	if isHebrewLocale(lang) {
+		return []string{
+			"תאריך",
+			"פרשה / חג",
+			"הדלקת נרות",
+			"שקיעה",
+			fmt.Sprintf("מנחה (%d דקות לפני הדלקת נרות)", minchaMins),
+			"מנחה מוקדמת",
+			"ק״ש (מג״א)",
+			"ק״ש (גר״א)",
+			"מנחה גדולה",
+			"הבדלה",
+			"הדלקת נרות (יום טוב)",
+		}
+	}
+	return []string{
+		"Date",
+		"Parsha / Holiday",
+		"Candle lighting",
+		"Sunset",
+		fmt.Sprintf("Mincha (%d min before candles)", minchaMins),
+		"Earliest Mincha",
+		"Shema (MGA)",
+		"Shema (GRA)",
+		"Mincha Gedolah",
+		"Havdalah",
+		"Candle lighting (Yom Tov)",
+	}
+}
+
+func (row *shabbatRow) times() []time.Time {
+	return []time.Time{row.CandleLighting, row.Sunset, row.Mincha, row.EarliestMincha,
+		row.ShemaMGA, row.ShemaGRA, row.MinchaGedolah, row.Havdalah, row.YomTovCandles}
+}
+
+// timedEventOn returns the time of the first event on abs with the
+// given description, or the zero time.
+func timedEventOn(days map[int64][]event.CalEvent, abs int64, desc string) time.Time {
+	for _, ev := range days[abs] {
+		if timedEv, ok := ev.(hebcal.TimedEvent); ok && timedEv.Desc == desc {
+			return timedEv.EventTime
+		}
+	}
+	return time.Time{}
+}
+
+// holidayOn returns the first holiday on abs with any of the flags.
+func holidayOn(days map[int64][]event.CalEvent, abs int64, flags event.HolidayFlags) event.CalEvent {
+	for _, ev := range days[abs] {
+		if _, ok := ev.(hebcal.TimedEvent); ok {
+			continue
+		}
+		if _, ok := ev.(event.HolidayEvent); ok && ev.GetFlags()&flags != 0 {
+			return ev
+		}
+	}
+	return nil
+}
+
+// newShabbatRow fills in the times for the Shabbat or Yom Tov on hd
+// from the candle lighting and Havdalah events hebcal-go calculated.
+func newShabbatRow(hd hdate.HDate, title string, days map[int64][]event.CalEvent, obs observer) shabbatRow {
+	abs := hd.Abs()
+	erev := hd.Prev()
+	zErev := newDayZmanim(obs, erev)
+	z := newDayZmanim(obs, hd)
+	row := shabbatRow{
+		Date:           hd,
+		Title:          title,
+		CandleLighting: timedEventOn(days, abs-1, "Candle lighting"),
+		Sunset:         zErev.Sunset(),
+		EarliestMincha: zErev.hourOffset(6.5),
+		ShemaMGA:       z.shaahOffset(3, dayMinutes(72)),
+		ShemaGRA:       z.hourOffset(3),
+		MinchaGedolah:  z.hourOffset(6.5),
+		Havdalah:       timedEventOn(days, abs, "Havdalah"),
+	}
+	// Yom Tov that follows begins with candle lighting after dark
+	if t := timedEventOn(days, abs, "Candle lighting"); t.After(z.Sunset()) {
+		row.YomTovCandles = t
+	}
+	// no Mincha before candles lit after dark, e.g. Yom Tov on Motzei Shabbat
+	if !row.CandleLighting.IsZero() && row.CandleLighting.Before(row.Sunset) {
+		row.Mincha = row.CandleLighting.Add(time.Duration(-minchaMins) * time.Minute)
+	}
+	return row
+}
+
+// shabbatTableRows builds one row for each Shabbat in the calendar
+// range, and one for each Yom Tov day that isn't on Shabbat.
+func shabbatTableRows(events []event.CalEvent, obs observer, calOptions *hebcal.CalOptions) []shabbatRow {
+	start, end := calendarRange(calOptions)
+	days := eventsByDay(events)
+	rows := make([]shabbatRow, 0, 60)
+	sedraYears := make(map[int]sedra.Sedra)
+	for abs := hdate.DayOnOrBefore(time.Saturday, start.Abs()+6); abs <= end.Abs(); abs += 7 {
+		hd := hdate.FromRD(abs)
+		sy, ok := sedraYears[hd.Year()]
+		if !ok {
+			sy = sedra.New(hd.Year(), calOptions.IL)
+			sedraYears[hd.Year()] = sy
+		}
+		title := ""
+		if parsha := sy.LookupByRD(abs); !parsha.Chag {
+			title = event.NewParshaEvent(hd, parsha, calOptions.IL).Render(lang)
+		} else if ev := holidayOn(days, abs, event.CHAG|event.CHOL_HAMOED); ev != nil {
+			title = ev.Render(lang)
+		}
+		rows = append(rows, newShabbatRow(hd, title, days, obs))
+	}
+	for abs := start.Abs(); abs <= end.Abs(); abs++ {
+		if abs%7 == int64(time.Saturday) {
+			continue
+		}
+		if ev := holidayOn(days, abs, event.CHAG); ev != nil {
+			rows = append(rows, newShabbatRow(hdate.FromRD(abs), ev.Render(lang), days, obs))
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Date.Abs() < rows[j].Date.Abs()
+	})
+	return rows
+}
+
+// writeShabbatTable writes the --shabbat-table in the --format.
+func writeShabbatTable(out io.Writer, events []event.CalEvent, obs observer, calOptions *hebcal.CalOptions) error {
+	rows := shabbatTableRows(events, obs, calOptions)
+	switch outputFormat {
+	case "csv":
+		return writeShabbatTableCSV(out, rows, calOptions)
+	case "html":
+		return writeShabbatTableHTML(out, rows, calOptions)
+	}
+	return writeShabbatTableText(out, rows, calOptions)
+}
+
+func shabbatTableRecords(rows []shabbatRow, timeFormat func(t time.Time) string) [][]string {
+	records := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		record := []string{formatGregDate(row.Date), row.Title}
+		for _, t := range row.times() {
+			str := ""
+			if !t.IsZero() {
+				str = timeFormat(t)
+			}
+			record = append(record, str)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func writeShabbatTableText(out io.Writer, rows []shabbatRow, calOptions *hebcal.CalOptions) error {
+	header := shabbatTableHeader()
+	records := shabbatTableRecords(rows, func(t time.Time) string {
+		return clockTime(t, calOptions.Hour24)
+	})
+	widths := make([]int, len(header))
+	for _, record := range append([][]string{header}, records...) {
+		for i, str := range record {
+			if n := displayWidth(str); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	w := bufio.NewWriter(out)
+	for _, record := range append([][]string{header}, records...) {
+		if tabs_sw {
+			fmt.Fprintln(w, strings.Join(record, "\t"))
+			continue
+		}
+		var sb strings.Builder
+		for i, str := range record {
+			sb.WriteString(padRight(str, widths[i]+2))
+		}
+		fmt.Fprintln(w, strings.TrimRight(sb.String(), " "))
+	}
+	return w.Flush()
+}
+
+func writeShabbatTableCSV(out io.Writer, rows []shabbatRow, calOptions *hebcal.CalOptions) error {
+	if _, err := io.WriteString(out, "\ufeff"); err != nil {
+		return err
+	}
+	w := csv.NewWriter(out)
+	w.UseCRLF = true
+	timeFormat := "3:04 PM"
+	if calOptions.Hour24 {
+		timeFormat = "15:04"
+	}
+	records := shabbatTableRecords(rows, func(t time.Time) string {
+		return t.Format(timeFormat)
+	})
+	if err := w.Write(shabbatTableHeader()); err != nil {
+		return err
+	}
+	if err := w.WriteAll(records); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+type htmlShabbatTable struct {
+	Lang    string
+	Dir     string
+	Title   string
+	Header  []string
+	Records [][]string
+}
+
+func writeShabbatTableHTML(out io.Writer, rows []shabbatRow, calOptions *hebcal.CalOptions) error {
+	page := htmlShabbatTable{
+		Lang:   "en",
+		Dir:    "ltr",
+		Title:  "Shabbat times",
+		Header: shabbatTableHeader(),
+		Records: shabbatTableRecords(rows, func(t time.Time) string {
+			return clockTime(t, calOptions.Hour24)
+		}),
+	}
+	if isHebrewLocale(lang) {
+		page.Lang = "he"
+		page.Dir = "rtl"
+		page.Title = "זמני שבת"
+	}
+	if calOptions.Location != nil {
+		page.Title += " – " + calOptions.Location.Name
+	}
+	return htmlShabbatTableTemplate.Execute(out, page)
+}
+
+var htmlShabbatTableTemplate = template.Must(template.New("shabbat").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}" dir="{{.Dir}}">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+h1 { margin: 0 0 0.5em; font-size: 1.5em; }
+table { border-collapse: collapse; }
+th { background: #eee; border: 1px solid #999; padding: 0.2em 0.4em; }
+td { border: 1px solid #999; padding: 0.2em 0.4em; white-space: nowrap; }
+tr:nth-child(even) td { background: #f7f7f7; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Records}}
+<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
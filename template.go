@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hebcal/gematriya"
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+)
+
+// templateEvent is the data passed to a --template for each event
+type templateEvent struct {
+	Date      time.Time    // Gregorian date at midnight UTC
+	Year      int          // Gregorian year
+	Month     time.Month   // Gregorian month
+	Day       int          // Gregorian day of month
+	Weekday   time.Weekday // day of the week
+	HDate     hdate.HDate  // Hebrew date
+	HYear     int          // Hebrew year
+	HMonth    string       // Hebrew month name in --lang
+	HDay      int          // Hebrew day of month
+	Title     string       // rendered in --lang, without the time for timed events
+	Basename  string       // untranslated description
+	Emoji     string
+	Flags     []string
+	Timed     bool      // true for candle-lighting, Havdalah, zmanim, ...
+	EventTime time.Time // zero unless Timed
+	Event     event.CalEvent
+}
+
+// Render returns the description of the event in the given locale.
+func (te templateEvent) Render(locale string) string {
+	return te.Event.Render(locale)
+}
+
+func newTemplateEvent(ev event.CalEvent) templateEvent {
+	hd := ev.GetDate()
+	year, month, day := hd.Greg()
+	te := templateEvent{
+		Date:     time.Date(year, month, day, 0, 0, 0, 0, time.UTC),
+		Year:     year,
+		Month:    month,
+		Day:      day,
+		Weekday:  hd.Weekday(),
+		HDate:    hd,
+		HYear:    hd.Year(),
+		HMonth:   hd.MonthName(lang),
+		HDay:     hd.Day(),
+		Title:    eventTitle(ev, lang),
+		Basename: ev.Basename(),
+		Emoji:    ev.GetEmoji(),
+		Flags:    flagNames(ev.GetFlags()),
+		Event:    ev,
+	}
+	if timedEv, ok := ev.(hebcal.TimedEvent); ok {
+		te.Timed = true
+		te.EventTime = timedEv.EventTime
+	}
+	return te
+}
+
+func weekdayName(dow time.Weekday) string {
+	if isHebrewLocale(lang) {
+		return heWeekdayNames[dow]
+	}
+	return dow.String()
+}
+
+// newEventTemplate parses a --template. The helper functions are:
+//
+//	gematriya N        Hebrew numerals, e.g. {{gematriya .HYear}}
+//	weekday DAY        weekday name in --lang
+//	formatTime LAYOUT T  Go time layout, e.g. {{formatTime "15:04" .EventTime}}
+//	clock T            time of day honoring -E
+//	gregDate HDATE     Gregorian date honoring -e, -g and -y
+//	join LIST SEP      strings.Join
+func newEventTemplate(text string, hour24 bool) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"gematriya": gematriya.Gematriya,
+		"weekday":   weekdayName,
+		"formatTime": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+		"clock": func(t time.Time) string {
+			if hour24 {
+				return t.Format("15:04")
+			}
+			return strings.TrimRight(t.Format(time.Kitchen), "AMP")
+		},
+		"gregDate": formatGregDate,
+		"join":     strings.Join,
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return template.New("event").Funcs(funcs).Parse(text)
+}
+
+// writeTemplate executes tmpl once for each event.
+func writeTemplate(out io.Writer, events []event.CalEvent, tmpl *template.Template) error {
+	w := bufio.NewWriter(out)
+	for _, ev := range events {
+		if err := tmpl.Execute(w, newTemplateEvent(ev)); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
@@ -3,82 +3,243 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/hebcal/greg"
 	"github.com/hebcal/hdate"
 	"github.com/hebcal/hebcal-go/hebcal"
 )
 
-func readUserFile(filename string) []hebcal.UserEvent {
-	f, err := os.Open(filename)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "could not open input file %s.\n", filename)
-		os.Exit(1)
+// fieldError is a problem with one whitespace-separated field of a line,
+// numbered from 0.
+type fieldError struct {
+	Field int
+	Msg   string
+}
+
+func (e fieldError) Error() string {
+	return e.Msg
+}
+
+func fieldErrorf(field int, format string, a ...interface{}) error {
+	return fieldError{Field: field, Msg: fmt.Sprintf(format, a...)}
+}
+
+var fieldRegex = regexp.MustCompile(`\S+`)
+
+// fieldColumn returns the 1-based column of a field in line, or the
+// column after the end of the line if there are fewer fields.
+func fieldColumn(line string, field int) int {
+	loc := fieldRegex.FindAllStringIndex(line, -1)
+	if field < len(loc) {
+		return len([]rune(line[:loc[field][0]])) + 1
 	}
-	scanner := bufio.NewScanner(f)
-	re := regexp.MustCompile(`^(\S+)\s+(\d+)\s+(.+)$`)
+	return len([]rune(strings.TrimRight(line, " \t"))) + 2
+}
+
+// scanInputFile calls parse for each line of an input file, skipping
+// blank lines and comments beginning with #. Each problem is reported
+// on stderr with its file name, line and column. It returns the number
+// of problems found.
+func scanInputFile(r io.Reader, filename string, parse func(line string) error) int {
+	scanner := bufio.NewScanner(r)
 	lineNumber := 0
-	entries := make([]hebcal.UserEvent, 0, 10)
+	numErrors := 0
 	for scanner.Scan() {
-		line0 := scanner.Text()
-		line := strings.TrimSpace(line0)
+		line := scanner.Text()
 		lineNumber++
-		fields := re.FindStringSubmatch(line)
-		if len(fields) != 4 {
-			fmt.Fprintf(os.Stderr, "error, invalid format: %s:%d\n", filename, lineNumber)
+		if trimmed := strings.TrimSpace(line); trimmed == "" || trimmed[0] == '#' {
 			continue
 		}
-		month, err := hdate.MonthFromName(fields[1])
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "error, invalid month: %s:%d\n", filename, lineNumber)
-			continue
+		if err := parse(line); err != nil {
+			column := 1
+			if fe, ok := err.(fieldError); ok {
+				column = fieldColumn(line, fe.Field)
+			}
+			fmt.Fprintf(os.Stderr, "error, %v: %s:%d:%d\n", err, filename, lineNumber, column)
+			numErrors++
 		}
-		day, _ := strconv.Atoi(fields[2])
-		if day < 1 || day > 30 {
-			fmt.Fprintf(os.Stderr, "error, invalid days: %s:%d\n", filename, lineNumber)
-		}
-		entries = append(entries, hebcal.UserEvent{Month: month, Day: day, Desc: fields[3]})
 	}
-	return entries
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "error, %v: %s:%d\n", err, filename, lineNumber)
+		numErrors++
+	}
+	return numErrors
 }
 
-func readYahrzeitFile(filename string) []hebcal.UserYahrzeit {
+// readInputFile opens filename and scans it. Problems are fatal if
+// --strict is in effect.
+func readInputFile(filename string, what string, parse func(line string) error) {
 	f, err := os.Open(filename)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "could not open yahrtzeit input file %s.\n", filename)
+		fmt.Fprintf(os.Stderr, "could not open %sinput file %s.\n", what, filename)
 		os.Exit(1)
 	}
-	scanner := bufio.NewScanner(f)
-	re := regexp.MustCompile(`^(\d+)\s+(\d+)\s+(\d+)\s+(.+)$`)
-	lineNumber := 0
-	entries := make([]hebcal.UserYahrzeit, 0, 10)
-	for scanner.Scan() {
-		line0 := scanner.Text()
-		line := strings.TrimSpace(line0)
-		lineNumber++
-		fields := re.FindStringSubmatch(line)
-		if len(fields) != 5 {
-			fmt.Fprintf(os.Stderr, "error, invalid format: %s:%d\n", filename, lineNumber)
-			continue
+	defer f.Close()
+	if scanInputFile(f, filename, parse) != 0 && strict_sw {
+		os.Exit(1)
+	}
+}
+
+// readUserFile reads a -I file. Fixed Hebrew dates are returned as
+// hebcal.UserEvent; other rules (see parseUserRule) are returned separately
+// to be expanded by userRuleEvents.
+func readUserFile(filename string) ([]hebcal.UserEvent, []userRule) {
+	entries := make([]hebcal.UserEvent, 0, 10)
+	rules := make([]userRule, 0, 10)
+	readInputFile(filename, "", func(line string) error {
+		r, err := parseUserRule(line)
+		if err != nil {
+			return err
 		}
-		month0, _ := strconv.Atoi(fields[1])
-		if month0 < 1 || month0 > 12 {
-			fmt.Fprintf(os.Stderr, "error, invalid month: %s:%d\n", filename, lineNumber)
-			continue
+		if r.simple() {
+			entries = append(entries, hebcal.UserEvent{Month: r.HMonth, Day: r.Day, Desc: r.Desc})
+		} else {
+			rules = append(rules, r)
+		}
+		return nil
+	})
+	return entries, rules
+}
+
+// afterSunsetRegex matches the optional marker between the date of
+// death and the name
+var afterSunsetRegex = regexp.MustCompile(`(?i)^after-sunset\s+(.+)$`)
+
+func readYahrzeitFile(filename string) []hebcal.UserYahrzeit {
+	entries := make([]hebcal.UserYahrzeit, 0, 10)
+	readInputFile(filename, "yahrtzeit ", func(line string) error {
+		yahrzeit, err := parseYahrzeitLine(line)
+		if err == nil {
+			entries = append(entries, yahrzeit)
 		}
-		day, _ := strconv.Atoi(fields[2])
-		year, _ := strconv.Atoi(fields[3])
-		month := time.Month(month0)
-		if day < 1 || day > greg.DaysIn(month, year) {
-			fmt.Fprintf(os.Stderr, "error, invalid days: %s:%d\n", filename, lineNumber)
+		return err
+	})
+	return entries
+}
+
+// parseYahrzeitLine parses one line of a -Y file:
+//
+//	MM DD YYYY [after-sunset] Name
+//	MMMM DD YYYY Name
+func parseYahrzeitLine(line string) (hebcal.UserYahrzeit, error) {
+	hd, name, err := parseUserDate(line, 0)
+	if err != nil {
+		return hebcal.UserYahrzeit{}, err
+	}
+	return hebcal.UserYahrzeit{Date: hd.Gregorian(), Name: name}, nil
+}
+
+// parseUserDate parses the Gregorian or Hebrew date beginning at the
+// given field of a -Y or -B line, and returns it with the rest of the
+// line.
+func parseUserDate(line string, field int) (hdate.HDate, string, error) {
+	fields := strings.Fields(line)
+	if len(fields) < field+4 {
+		return hdate.HDate{}, "", fieldErrorf(0, "invalid format")
+	}
+	loc := fieldRegex.FindAllStringIndex(line, -1)
+	name := strings.TrimSpace(line[loc[field+3][0]:])
+	afterSunset := false
+	if m := afterSunsetRegex.FindStringSubmatch(name); m != nil {
+		afterSunset = true
+		name = m[1]
+	}
+	monthStr, dayStr, yearStr := fields[field], fields[field+1], fields[field+2]
+	month, err := strconv.Atoi(monthStr)
+	if err != nil {
+		// Hebrew date, e.g. "Adar2 13 5770"
+		if afterSunset {
+			return hdate.HDate{}, "", fieldErrorf(field+3, "after-sunset requires a Gregorian date")
 		}
-		gregDate := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
-		entries = append(entries, hebcal.UserYahrzeit{Date: gregDate, Name: fields[4]})
+		hd, err := parseHebrewUserDate(monthStr, dayStr, yearStr, field)
+		return hd, name, err
 	}
+	if month < 1 || month > 12 {
+		return hdate.HDate{}, "", fieldErrorf(field, "invalid month")
+	}
+	day, err := strconv.Atoi(dayStr)
+	if err != nil {
+		return hdate.HDate{}, "", fieldErrorf(field+1, "invalid days")
+	}
+	year, err := strconv.Atoi(yearStr)
+	if err != nil {
+		return hdate.HDate{}, "", fieldErrorf(field+2, "invalid year")
+	}
+	hd, err := newGregDate(year, time.Month(month), day)
+	if err != nil {
+		return hdate.HDate{}, "", fieldErrorf(field+1, "%v", err)
+	}
+	if afterSunset {
+		// the Hebrew day begins at sunset
+		hd = hd.Next()
+	}
+	return hd, name, nil
+}
+
+// parseHebrewUserDate parses the MONTH DAY YEAR fields of a Hebrew
+// date, the first of which is the given field of the line.
+func parseHebrewUserDate(monthStr, dayStr, yearStr string, field int) (hdate.HDate, error) {
+	year, err := parseHebrewYear(yearStr)
+	if err != nil {
+		return hdate.HDate{}, fieldErrorf(field+2, "invalid year")
+	}
+	month, err := parseHebrewMonth(monthStr, year)
+	if err != nil {
+		return hdate.HDate{}, fieldErrorf(field, "invalid month")
+	}
+	day, err := parseNumber(dayStr)
+	if err != nil {
+		return hdate.HDate{}, fieldErrorf(field+1, "invalid days")
+	}
+	hd, err := newHDate(year, month, day)
+	if err != nil {
+		return hdate.HDate{}, fieldErrorf(field+1, "%v", err)
+	}
+	return hd, nil
+}
+
+// readBirthdayFile reads Hebrew birthdays and anniversaries, one per line:
+//
+//	TYPE MM DD YYYY [after-sunset] Name[; Note]
+//	TYPE MMMM DD YYYY Name[; Note]
+//
+// where TYPE is "birthday" or "anniversary".
+func readBirthdayFile(filename string) []userAnniversary {
+	entries := make([]userAnniversary, 0, 10)
+	readInputFile(filename, "birthdays ", func(line string) error {
+		entry, err := parseBirthdayLine(line)
+		if err == nil {
+			entries = append(entries, entry)
+		}
+		return err
+	})
 	return entries
 }
+
+func parseBirthdayLine(line string) (userAnniversary, error) {
+	fields := strings.Fields(line)
+	var entryType AnniversaryType
+	switch strings.ToLower(fields[0]) {
+	case "birthday":
+		entryType = BIRTHDAY
+	case "anniversary":
+		entryType = ANNIVERSARY
+	default:
+		return userAnniversary{}, fieldErrorf(0, "invalid type %s", fields[0])
+	}
+	hd, name, err := parseUserDate(line, 1)
+	if err != nil {
+		return userAnniversary{}, err
+	}
+	note := ""
+	if i := strings.Index(name, ";"); i != -1 {
+		note = strings.TrimSpace(name[i+1:])
+		name = strings.TrimSpace(name[:i])
+	}
+	return userAnniversary{Type: entryType, Date: hd, Name: name, Note: note}, nil
+}
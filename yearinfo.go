@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hebcal/gematriya"
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/sedra"
+)
+
+// keviahDays are the Hebrew numerals for the days of the week, as
+// used in the keviah
+var keviahDays = []string{"א", "ב", "ג", "ד", "ה", "ו", "ז"}
+
+// keviahLatin transliterates the letters of a keviah
+var keviahLatin = map[string]string{
+	"א": "a", "ב": "b", "ג": "g", "ד": "d", "ה": "h", "ו": "v", "ז": "z",
+	"ח": "h", "כ": "k", "ש": "sh",
+}
+
+// yearLength describes a year as deficient, regular or complete,
+// with the letter used for it in the keviah.
+func yearLength(year int) (string, string) {
+	switch {
+	case hdate.LongCheshvan(year):
+		return "ש", "complete (shlemah)"
+	case hdate.ShortKislev(year):
+		return "ח", "deficient (chaserah)"
+	}
+	return "כ", "regular (kesidrah)"
+}
+
+// keviah returns the sign of the year: the weekday of Rosh Hashana,
+// the year's length and the weekday of Pesach, e.g. "בשה".
+func keviah(year int) string {
+	rh := hdate.New(year, hdate.Tishrei, 1)
+	pesach := hdate.New(year, hdate.Nisan, 15)
+	letter, _ := yearLength(year)
+	return keviahDays[rh.Weekday()] + letter + keviahDays[pesach.Weekday()]
+}
+
+func keviahTransliteration(k string) string {
+	var sb strings.Builder
+	for _, r := range k {
+		sb.WriteString(keviahLatin[string(r)])
+	}
+	return sb.String()
+}
+
+// doubledParshiyot returns the parshiyot read together in year.
+func doubledParshiyot(year int, il bool) []string {
+	sy := sedra.New(year, il)
+	start := hdate.New(year, hdate.Tishrei, 1)
+	end := hdate.New(year+1, hdate.Tishrei, 1)
+	doubled := make([]string, 0, 7)
+	for abs := hdate.DayOnOrBefore(time.Saturday, start.Abs()+6); abs < end.Abs(); abs += 7 {
+		if parsha := sy.LookupByRD(abs); !parsha.Chag && len(parsha.Name) > 1 {
+			doubled = append(doubled, strings.Join(parsha.Name, "-"))
+		}
+	}
+	return doubled
+}
+
+// readingsDiverge describes when the Diaspora falls behind the weekly
+// reading in Israel because the last day of Pesach or the second day
+// of Shavuot is on Shabbat, or "" if it doesn't.
+func readingsDiverge(year int) string {
+	pesach8 := hdate.New(year, hdate.Nisan, 22)
+	shavuot2 := hdate.New(year, hdate.Sivan, 7)
+	switch {
+	case pesach8.Weekday() == time.Saturday:
+		return "the 8th day of Pesach is on Shabbat, so the Diaspora reads one parsha behind Israel until they are joined again"
+	case shavuot2.Weekday() == time.Saturday:
+		return "the 2nd day of Shavuot is on Shabbat, so the Diaspora reads one parsha behind Israel until they are joined again"
+	}
+	return ""
+}
+
+// birkatHaChamah returns the day of Birkat HaChamah, which is said in
+// the first year of the solar cycle on the day of Tekufat Nisan
+// according to Shmuel, 26 March in the Julian calendar.
+func birkatHaChamah(year int) hdate.HDate {
+	gregYear := year - 3760
+	julianOffset := gregYear/100 - gregYear/400 - 2
+	gy, gm, gd := time.Date(gregYear, time.March, 26+julianOffset, 0, 0, 0, 0, time.UTC).Date()
+	return hdate.FromGregorian(gy, gm, gd)
+}
+
+func parshiyotList(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func dayAndDate(hd hdate.HDate) string {
+	return hd.Weekday().String() + " " + formatGregDate(hd)
+}
+
+// runYearInfo implements "hebcal yearinfo [YEAR]".
+func runYearInfo(args []string) {
+	year := hdate.FromTime(time.Now()).Year()
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "Error, too many arguments: %s\n", strings.Join(args, " "))
+		os.Exit(1)
+	} else if len(args) == 1 {
+		var err error
+		year, err = strconv.Atoi(args[0])
+		if err != nil || year < 2 {
+			fmt.Fprintf(os.Stderr, "Error, invalid Hebrew year: %s\n", args[0])
+			os.Exit(1)
+		}
+	}
+	k := keviah(year)
+	_, length := yearLength(year)
+	metonic := (year-1)%19 + 1
+	solar := (year-1)%28 + 1
+	shemitah := (year-1)%7 + 1
+
+	lines := [][2]string{
+		{"Year", fmt.Sprintf("%d (%s)", year, gematriya.Gematriya(year))},
+		{"Keviah", fmt.Sprintf("%s (%s)", k, keviahTransliteration(k))},
+		{"Days", strconv.Itoa(hdate.DaysInYear(year))},
+		{"Length", length},
+		{"Leap year", yesNo(hdate.IsLeapYear(year))},
+		{"Metonic cycle", fmt.Sprintf("year %d of 19 (cycle %d)", metonic, (year-1)/19+1)},
+		{"Solar cycle", fmt.Sprintf("year %d of 28 (cycle %d)", solar, (year-1)/28+1)},
+	}
+	if solar == 1 {
+		lines = append(lines, [2]string{"Birkat HaChamah", dayAndDate(birkatHaChamah(year))})
+	}
+	shemitahStr := fmt.Sprintf("year %d of 7", shemitah)
+	if shemitah == 7 {
+		shemitahStr = "Shemitah year"
+	}
+	lines = append(lines,
+		[2]string{"Shemitah", shemitahStr},
+		[2]string{"Rosh Hashana", dayAndDate(hdate.New(year, hdate.Tishrei, 1))},
+		[2]string{"Pesach", dayAndDate(hdate.New(year, hdate.Nisan, 15))},
+		[2]string{"Shavuot", dayAndDate(hdate.New(year, hdate.Sivan, 6))},
+		[2]string{"Doubled parshiyot", parshiyotList(doubledParshiyot(year, false))},
+		[2]string{"Doubled parshiyot (Israel)", parshiyotList(doubledParshiyot(year, true))},
+	)
+	if diverge := readingsDiverge(year); diverge != "" {
+		lines = append(lines, [2]string{"Israel and Diaspora", diverge})
+	}
+	for _, line := range lines {
+		fmt.Printf("%-28s%s\n", line[0]+":", line[1])
+	}
+	os.Exit(0)
+}
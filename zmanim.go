@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+	"github.com/hebcal/hebcal-go/zmanim"
+	"github.com/nathan-osman/go-sunrise"
+)
+
+// earthRadiusKm is the radius used by KosherJava for the dip of the
+// horizon seen from an elevation
+const earthRadiusKm = 6356.9
+
+// dayZmanim is a zmanim.Zmanim for an observer at an elevation above
+// sea level, who sees the sun rise earlier and set later. Like
+// KosherJava, only sunrise and sunset (and the times derived from
+// them) are adjusted; times defined by the sun's angle below the
+// horizon are the same at any elevation.
+type dayZmanim struct {
+	zmanim.Zmanim
+	Elevation float64 // meters
+	loc       *time.Location
+}
+
+// observer is a location for zmanim with its elevation above sea
+// level in meters, which is known for places from 'hebcal geo-import'
+// and can be set with --elevation.
+type observer struct {
+	*zmanim.Location
+	Elevation float64
+}
+
+func newDayZmanim(obs observer, hd hdate.HDate) dayZmanim {
+	year, month, day := hd.Greg()
+	z := zmanim.New(obs.Location, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+	loc, _ := time.LoadLocation(obs.TimeZoneId) // zmanim.New has already checked it
+	return dayZmanim{Zmanim: z, Elevation: obs.Elevation, loc: loc}
+}
+
+// horizonDip is how many degrees below the mathematical horizon
+// the visible horizon is from the observer's elevation.
+func (z *dayZmanim) horizonDip() float64 {
+	if z.Elevation <= 0 {
+		return 0
+	}
+	return math.Acos(earthRadiusKm/(earthRadiusKm+z.Elevation/1000)) * 180 / math.Pi
+}
+
+func (z *dayZmanim) inLoc(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.In(z.loc)
+}
+
+func (z *dayZmanim) riseSet() (time.Time, time.Time) {
+	rise, set := sunrise.TimeOfElevation(z.Location.Latitude, z.Location.Longitude,
+		-(0.833 + z.horizonDip()), z.Year, z.Month, z.Day)
+	return z.inLoc(rise), z.inLoc(set)
+}
+
+// Sunrise is when the upper edge of the sun appears over the visible horizon.
+func (z *dayZmanim) Sunrise() time.Time {
+	if z.Elevation <= 0 {
+		return z.Zmanim.Sunrise()
+	}
+	rise, _ := z.riseSet()
+	return rise
+}
+
+// Sunset is when the upper edge of the sun disappears below the visible horizon.
+func (z *dayZmanim) Sunset() time.Time {
+	if z.Elevation <= 0 {
+		return z.Zmanim.Sunset()
+	}
+	_, set := z.riseSet()
+	return set
+}
+
+// timeAtAngle returns when the sun is angle degrees below the horizon
+// in the morning or, if rising is false, in the evening.
+func (z *dayZmanim) timeAtAngle(angle float64, rising bool) time.Time {
+	morning, evening := sunrise.TimeOfElevation(z.Location.Latitude, z.Location.Longitude,
+		-angle, z.Year, z.Month, z.Day)
+	if rising {
+		return z.inLoc(morning)
+	}
+	return z.inLoc(evening)
+}
+
+// hour returns the number of seconds in a halachic hour, 1/12 of the
+// time from sunrise to sunset.
+func (z *dayZmanim) hour() float64 {
+	return float64(z.Sunset().Unix()-z.Sunrise().Unix()) / 12.0
+}
+
+// zmanimDay is a definition of the halachic day, from which sha'ot
+// zmaniyot (proportional hours) are counted.
+type zmanimDay func(z *dayZmanim) (time.Time, time.Time)
+
+// dayGRA is the day from sunrise to sunset, according to the Gra.
+func dayGRA(z *dayZmanim) (time.Time, time.Time) {
+	return z.Sunrise(), z.Sunset()
+}
+
+// dayMinutes is the day from the given number of minutes before
+// sunrise to the same number after sunset; 72 minutes is the day
+// according to Magen Avraham.
+func dayMinutes(minutes int) zmanimDay {
+	return func(z *dayZmanim) (time.Time, time.Time) {
+		return z.offset(z.Sunrise(), -minutes, false), z.offset(z.Sunset(), minutes, false)
+	}
+}
+
+// dayDegrees is the day from when the sun is angle degrees below the
+// horizon in the morning to the same angle in the evening.
+func dayDegrees(angle float64) zmanimDay {
+	return func(z *dayZmanim) (time.Time, time.Time) {
+		return z.timeAtAngle(angle, true), z.timeAtAngle(angle, false)
+	}
+}
+
+// dayAteretTorah is the day from 72 proportional minutes before sunrise
+// to 40 minutes after sunset, according to Chacham Yosef Harari-Raful
+// of Yeshivat Ateret Torah.
+func dayAteretTorah(z *dayZmanim) (time.Time, time.Time) {
+	return z.zmaniyotOffset(z.Sunrise(), -72), z.offset(z.Sunset(), 40, false)
+}
+
+// shaahOffset returns the beginning of the day plus the given number
+// of sha'ot zmaniyot, each 1/12 of the day.
+func (z *dayZmanim) shaahOffset(hours float64, day zmanimDay) time.Time {
+	start, end := day(z)
+	if start.IsZero() || end.IsZero() {
+		return time.Time{}
+	}
+	hour := float64(end.Unix()-start.Unix()) / 12.0
+	return time.Unix(start.Unix()+int64(hour*hours), 0).In(z.loc)
+}
+
+// hourOffset returns sunrise plus the given number of halachic hours.
+func (z *dayZmanim) hourOffset(hours float64) time.Time {
+	return z.shaahOffset(hours, dayGRA)
+}
+
+// zmaniyotOffset returns t plus the given number of proportional
+// minutes, each 1/60 of a halachic hour.
+func (z *dayZmanim) zmaniyotOffset(t time.Time, minutes float64) time.Time {
+	if t.IsZero() || z.Sunrise().IsZero() || z.Sunset().IsZero() {
+		return time.Time{}
+	}
+	return time.Unix(t.Unix()+int64(z.hour()*minutes/60), 0).In(z.loc)
+}
+
+// offset returns t plus the given number of minutes. Like
+// zmanim.Zmanim.SunsetOffset, with roundTime the seconds are dropped,
+// rounding up positive offsets.
+func (z *dayZmanim) offset(t time.Time, minutes int, roundTime bool) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+	if roundTime {
+		if minutes > 0 && sec >= 30 {
+			minutes++
+		}
+		sec = 0
+	}
+	return time.Date(year, month, day, hour, min+minutes, sec, 0, z.loc)
+}
+
+// candleLightingOffset returns the minutes from sunset of a candle
+// lighting or Havdalah time calculated by hebcal-go, following the
+// rules of its makeCandleEvent, or 0 if the time is not based on
+// sunset.
+func candleLightingOffset(ev hebcal.TimedEvent, calOptions *hebcal.CalOptions) int {
+	const candleFlags = event.LIGHT_CANDLES | event.LIGHT_CANDLES_TZEIS |
+		event.CHANUKAH_CANDLES | event.YOM_TOV_ENDS
+	if ev.Flags&candleFlags == 0 {
+		return 0
+	}
+	dow := ev.Date.Weekday()
+	if ev.Flags&event.CHANUKAH_CANDLES != 0 && dow != time.Friday && dow != time.Saturday {
+		return 0 // Bein HaShemashot
+	}
+	useHavdalahOffset := dow == time.Saturday ||
+		(dow != time.Friday && ev.Flags&(event.LIGHT_CANDLES_TZEIS|event.CHANUKAH_CANDLES|event.YOM_TOV_ENDS) != 0)
+	if useHavdalahOffset {
+		return calOptions.HavdalahMins
+	}
+	return calOptions.CandleLightingMins
+}
+
+// adjustForElevation recalculates the times hebcal-go calculated at
+// sea level that depend on sunrise or sunset: candle lighting and
+// Havdalah in minutes, the start of Tish'a B'Av and -O. The -Z times
+// are replaced by replaceDailyZmanim.
+func adjustForElevation(events []event.CalEvent, obs observer, calOptions *hebcal.CalOptions) []event.CalEvent {
+	if obs.Elevation <= 0 || obs.Location == nil {
+		return events
+	}
+	for i, ev := range events {
+		if ev == nil {
+			continue
+		}
+		timedEv, ok := ev.(hebcal.TimedEvent)
+		if !ok {
+			if ev.GetFlags() == event.ZMANIM && strings.HasPrefix(ev.Render("en"), "Sunrise: ") {
+				events[i] = riseSetEvent{date: ev.GetDate(), obs: obs, opts: calOptions}
+			}
+			continue
+		}
+		z := newDayZmanim(obs, timedEv.Date)
+		if offset := candleLightingOffset(timedEv, calOptions); offset != 0 {
+			timedEv.EventTime = z.offset(z.Sunset(), offset, true)
+		} else if timedEv.Desc == "Fast begins" && timedEv.LinkedEvent != nil &&
+			timedEv.LinkedEvent.Render("en") == "Erev Tish'a B'Av" {
+			timedEv.EventTime = z.Sunset()
+		} else {
+			continue
+		}
+		if timedEv.EventTime.IsZero() {
+			events[i] = nil
+		} else {
+			events[i] = timedEv
+		}
+	}
+	// drop times on days the sun doesn't rise or set
+	kept := events[:0]
+	for _, ev := range events {
+		if ev != nil {
+			kept = append(kept, ev)
+		}
+	}
+	return kept
+}
+
+// riseSetEvent replaces hebcal-go's -O event of the same name,
+// which doesn't know about elevation.
+type riseSetEvent struct {
+	date hdate.HDate
+	obs  observer
+	opts *hebcal.CalOptions
+}
+
+func (ev riseSetEvent) GetDate() hdate.HDate {
+	return ev.date
+}
+
+func (ev riseSetEvent) Render(locale string) string {
+	z := newDayZmanim(ev.obs, ev.date)
+	rise := z.Sunrise()
+	set := z.Sunset()
+	return fmt.Sprintf("Sunrise: %s; Sunset %s", clockTime(rise, ev.opts.Hour24), clockTime(set, ev.opts.Hour24))
+}
+
+func (ev riseSetEvent) GetFlags() event.HolidayFlags {
+	return event.ZMANIM
+}
+
+func (ev riseSetEvent) GetEmoji() string {
+	return ""
+}
+
+func (ev riseSetEvent) Basename() string {
+	return ev.Render("en")
+}
+
+// clockTime formats a time of day as hebcal-go does, honoring -E.
+func clockTime(t time.Time, hour24 bool) string {
+	if hour24 {
+		return t.Format("15:04")
+	}
+	return strings.TrimRight(t.Format(time.Kitchen), "AMP")
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hebcal/hdate"
+	"github.com/hebcal/hebcal-go/event"
+	"github.com/hebcal/hebcal-go/hebcal"
+	"github.com/hebcal/hebcal-go/zmanim"
+)
+
+// zman is one of the times printed every day by -Z
+type zman struct {
+	Desc string
+	Time func(z *dayZmanim) time.Time
+}
+
+func atAngle(angle float64, rising bool) func(z *dayZmanim) time.Time {
+	return func(z *dayZmanim) time.Time {
+		return z.timeAtAngle(angle, rising)
+	}
+}
+
+func hoursInto(hours float64, day zmanimDay) func(z *dayZmanim) time.Time {
+	return func(z *dayZmanim) time.Time {
+		return z.shaahOffset(hours, day)
+	}
+}
+
+func afterSunset(minutes int) func(z *dayZmanim) time.Time {
+	return func(z *dayZmanim) time.Time {
+		return z.offset(z.Sunset(), minutes, false)
+	}
+}
+
+var (
+	zmanSunrise = zman{"Sunrise", (*dayZmanim).Sunrise}
+	zmanSunset  = zman{"Sunset", (*dayZmanim).Sunset}
+	zmanChatzot = zman{"Chatzot hayom", hoursInto(6, dayGRA)}
+	zmanAlot    = zman{"Alot haShachar", atAngle(16.1, true)}
+	zmanMishey  = zman{"Misheyakir", atAngle(11.5, true)}
+	zmanTzeit   = zman{"Tzeit HaKochavim", atAngle(zmanim.Tzeit3SmallStars, false)}
+)
+
+// zmanimSets are the opinions selectable with --zmanim-set. The
+// default is the list hebcal-go prints for -Z.
+var zmanimSets = map[string][]zman{
+	"default": {
+		zmanAlot,
+		zmanMishey,
+		{"Misheyakir Machmir", atAngle(10.2, true)},
+		zmanSunrise,
+		{"Kriat Shema, sof zeman (MGA)", hoursInto(3, dayMinutes(72))},
+		{"Kriat Shema, sof zeman (GRA)", hoursInto(3, dayGRA)},
+		{"Tefilah, sof zeman (MGA)", hoursInto(4, dayMinutes(72))},
+		{"Tefilah, sof zeman (GRA)", hoursInto(4, dayGRA)},
+		zmanChatzot,
+		{"Mincha Gedolah", hoursInto(6.5, dayGRA)},
+		{"Mincha Ketanah", hoursInto(9.5, dayGRA)},
+		{"Plag HaMincha", hoursInto(10.75, dayGRA)},
+		zmanSunset,
+		{"Bein HaShemashot", func(z *dayZmanim) time.Time {
+			tzeit := z.timeAtAngle(zmanim.Tzeit3MediumStars, false)
+			if tzeit.IsZero() {
+				return tzeit
+			}
+			return tzeit.Add(zmanim.ThirteenFive)
+		}},
+		zmanTzeit,
+	},
+	"gra": {
+		zmanAlot,
+		zmanMishey,
+		zmanSunrise,
+		{"Kriat Shema, sof zeman (GRA)", hoursInto(3, dayGRA)},
+		{"Tefilah, sof zeman (GRA)", hoursInto(4, dayGRA)},
+		zmanChatzot,
+		{"Mincha Gedolah", hoursInto(6.5, dayGRA)},
+		{"Mincha Ketanah", hoursInto(9.5, dayGRA)},
+		{"Plag HaMincha", hoursInto(10.75, dayGRA)},
+		zmanSunset,
+		zmanTzeit,
+	},
+	"mga72": {
+		{"Alot haShachar (72 min)", func(z *dayZmanim) time.Time { return z.offset(z.Sunrise(), -72, false) }},
+		zmanMishey,
+		zmanSunrise,
+		{"Kriat Shema, sof zeman (MGA)", hoursInto(3, dayMinutes(72))},
+		{"Tefilah, sof zeman (MGA)", hoursInto(4, dayMinutes(72))},
+		zmanChatzot,
+		{"Mincha Gedolah (MGA)", hoursInto(6.5, dayMinutes(72))},
+		{"Mincha Ketanah (MGA)", hoursInto(9.5, dayMinutes(72))},
+		{"Plag HaMincha (MGA)", hoursInto(10.75, dayMinutes(72))},
+		zmanSunset,
+		{"Tzeit (72 min)", afterSunset(72)},
+	},
+	"mga16.1": {
+		{"Alot haShachar (16.1°)", atAngle(16.1, true)},
+		zmanMishey,
+		zmanSunrise,
+		{"Kriat Shema, sof zeman (MGA 16.1°)", hoursInto(3, dayDegrees(16.1))},
+		{"Tefilah, sof zeman (MGA 16.1°)", hoursInto(4, dayDegrees(16.1))},
+		zmanChatzot,
+		{"Mincha Gedolah (MGA 16.1°)", hoursInto(6.5, dayDegrees(16.1))},
+		{"Mincha Ketanah (MGA 16.1°)", hoursInto(9.5, dayDegrees(16.1))},
+		{"Plag HaMincha (MGA 16.1°)", hoursInto(10.75, dayDegrees(16.1))},
+		zmanSunset,
+		{"Tzeit (16.1°)", atAngle(16.1, false)},
+	},
+	"rabbeinu-tam": {
+		zmanAlot,
+		zmanMishey,
+		zmanSunrise,
+		{"Kriat Shema, sof zeman (GRA)", hoursInto(3, dayGRA)},
+		{"Tefilah, sof zeman (GRA)", hoursInto(4, dayGRA)},
+		zmanChatzot,
+		{"Mincha Gedolah", hoursInto(6.5, dayGRA)},
+		{"Mincha Ketanah", hoursInto(9.5, dayGRA)},
+		{"Plag HaMincha", hoursInto(10.75, dayGRA)},
+		zmanSunset,
+		{"Tzeit HaKochavim (8.5°)", atAngle(zmanim.Tzeit3SmallStars, false)},
+		{"Tzeit (Rabbeinu Tam, 72 min)", afterSunset(72)},
+	},
+	"ateret-torah": {
+		{"Alot haShachar (72 zmaniyot min)", func(z *dayZmanim) time.Time { return z.zmaniyotOffset(z.Sunrise(), -72) }},
+		zmanMishey,
+		zmanSunrise,
+		{"Kriat Shema, sof zeman (Ateret Torah)", hoursInto(3, dayAteretTorah)},
+		{"Tefilah, sof zeman (Ateret Torah)", hoursInto(4, dayAteretTorah)},
+		zmanChatzot,
+		{"Mincha Gedolah (Ateret Torah)", hoursInto(6.5, dayAteretTorah)},
+		{"Mincha Ketanah (Ateret Torah)", hoursInto(9.5, dayAteretTorah)},
+		{"Plag HaMincha (Ateret Torah)", hoursInto(10.75, dayAteretTorah)},
+		zmanSunset,
+		{"Tzeit (40 min)", afterSunset(40)},
+	},
+}
+
+func zmanimSetNames() []string {
+	names := make([]string, 0, len(zmanimSets))
+	for name := range zmanimSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var zmanOffsetRegex = regexp.MustCompile(`^(sunrise|sunset)\s*(?:([+-])\s*(\d+(?:\.\d+)?)\s*(z?))?$`)
+var zmanAngleRegex = regexp.MustCompile(`^(dawn|dusk):(\d+(?:\.\d+)?)$`)
+var zmanHoursRegex = regexp.MustCompile(`^hours:(\d+(?:\.\d+)?)(?::(.+))?$`)
+var zmanDayMinutesRegex = regexp.MustCompile(`^(\d+)min$`)
+var zmanDayDegreesRegex = regexp.MustCompile(`^(\d+(?:\.\d+)?)deg$`)
+
+// parseZman parses a --zman NAME=SPEC, where SPEC is one of
+//
+//	sunrise, sunset              the sun at the visible horizon
+//	sunrise-72, sunset+40        fixed minutes before or after
+//	sunrise-72z, sunset+90z      proportional minutes (1/60 of a halachic hour)
+//	dawn:16.1, dusk:8.5          the sun this many degrees below the horizon
+//	hours:3[:DAY]                sha'ot zmaniyot from the start of DAY
+//
+// and DAY is gra (sunrise to sunset, the default), mga (72 minutes
+// before sunrise to 72 after sunset), NNmin, DEGdeg or ateret-torah.
+func parseZman(arg string) (zman, error) {
+	i := strings.Index(arg, "=")
+	if i <= 0 {
+		return zman{}, fmt.Errorf("--zman must be NAME=SPEC: %s", arg)
+	}
+	name := strings.TrimSpace(arg[:i])
+	spec := strings.ToLower(strings.TrimSpace(arg[i+1:]))
+	if m := zmanOffsetRegex.FindStringSubmatch(spec); m != nil {
+		rising := m[1] == "sunrise"
+		minutes := 0.0
+		if m[2] != "" {
+			minutes, _ = strconv.ParseFloat(m[3], 64)
+			if m[2] == "-" {
+				minutes = -minutes
+			}
+		}
+		proportional := m[4] == "z"
+		return zman{name, func(z *dayZmanim) time.Time {
+			t := z.Sunset()
+			if rising {
+				t = z.Sunrise()
+			}
+			if proportional {
+				return z.zmaniyotOffset(t, minutes)
+			}
+			if t.IsZero() {
+				return t
+			}
+			return t.Add(time.Duration(minutes * float64(time.Minute)))
+		}}, nil
+	}
+	if m := zmanAngleRegex.FindStringSubmatch(spec); m != nil {
+		angle, _ := strconv.ParseFloat(m[2], 64)
+		if angle > 90 {
+			return zman{}, fmt.Errorf("angle out of range in --zman %s", arg)
+		}
+		return zman{name, atAngle(angle, m[1] == "dawn")}, nil
+	}
+	if m := zmanHoursRegex.FindStringSubmatch(spec); m != nil {
+		hours, _ := strconv.ParseFloat(m[1], 64)
+		if hours > 12 {
+			return zman{}, fmt.Errorf("more than 12 hours in --zman %s", arg)
+		}
+		day, err := parseZmanimDay(m[2])
+		if err != nil {
+			return zman{}, fmt.Errorf("%v in --zman %s", err, arg)
+		}
+		return zman{name, hoursInto(hours, day)}, nil
+	}
+	return zman{}, fmt.Errorf("unrecognized --zman %s", arg)
+}
+
+func parseZmanimDay(str string) (zmanimDay, error) {
+	switch str {
+	case "", "gra":
+		return dayGRA, nil
+	case "mga":
+		return dayMinutes(72), nil
+	case "ateret-torah":
+		return dayAteretTorah, nil
+	}
+	if m := zmanDayMinutesRegex.FindStringSubmatch(str); m != nil {
+		minutes, _ := strconv.Atoi(m[1])
+		return dayMinutes(minutes), nil
+	}
+	if m := zmanDayDegreesRegex.FindStringSubmatch(str); m != nil {
+		angle, _ := strconv.ParseFloat(m[1], 64)
+		return dayDegrees(angle), nil
+	}
+	return nil, errors.New("unknown day " + str)
+}
+
+// newZmanimList returns the -Z times for --zmanim-set and --zman. With
+// only --zman, just the custom times are printed unless -Z was also
+// given.
+func newZmanimList(setName string, specs []string, dailyZmanim bool) ([]zman, error) {
+	var list []zman
+	if setName != "" {
+		set, ok := zmanimSets[strings.ToLower(setName)]
+		if !ok {
+			return nil, fmt.Errorf("unknown zmanim set '%s'; must be one of %s",
+				setName, strings.Join(zmanimSetNames(), ", "))
+		}
+		list = append(list, set...)
+	} else if dailyZmanim {
+		list = append(list, zmanimSets["default"]...)
+	}
+	for _, spec := range specs {
+		z, err := parseZman(spec)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, z)
+	}
+	return list, nil
+}
+
+// dailyZmanimEvents returns the times in list for one day, in time
+// order, so that --zman times fall among those of the preset.
+func dailyZmanimEvents(hd hdate.HDate, list []zman, obs observer, calOptions *hebcal.CalOptions) []event.CalEvent {
+	z := newDayZmanim(obs, hd)
+	events := make([]hebcal.TimedEvent, 0, len(list))
+	for _, zman := range list {
+		if t := zman.Time(&z); !t.IsZero() {
+			events = append(events, hebcal.NewTimedEvent(hd, zman.Desc, event.ZMANIM, t, 0, nil, calOptions))
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].EventTime.Before(events[j].EventTime)
+	})
+	result := make([]event.CalEvent, len(events))
+	for i, ev := range events {
+		result[i] = ev
+	}
+	return result
+}
+
+// replaceDailyZmanim replaces the -Z times from hebcal-go with those
+// in list, keeping them in the same place in each day's events.
+func replaceDailyZmanim(events []event.CalEvent, list []zman, obs observer, calOptions *hebcal.CalOptions) []event.CalEvent {
+	if list == nil {
+		return events
+	}
+	result := make([]event.CalEvent, 0, len(events))
+	var lastAbs int64
+	for _, ev := range events {
+		if timedEv, ok := ev.(hebcal.TimedEvent); ok && timedEv.Flags == event.ZMANIM {
+			if abs := eventAbs(ev); abs != lastAbs {
+				result = append(result, dailyZmanimEvents(timedEv.Date, list, obs, calOptions)...)
+				lastAbs = abs
+			}
+			continue
+		}
+		result = append(result, ev)
+	}
+	return result
+}